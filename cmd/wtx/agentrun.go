@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Files written to a worktree's metadata directory by a headless agent run.
+const (
+	agentStatusFile = "agent.json"
+	agentLogFile    = "agent.log"
+	agentErrLogFile = "agent.stderr.log"
+	agentPromptFile = "agent.prompt"
+)
+
+// States of a headless agent run.
+const (
+	agentStateRunning  = "running"
+	agentStateFinished = "finished"
+	agentStateFailed   = "failed"
+)
+
+// agentStatus is the state of a headless agent run, kept next to the worktree
+// metadata.
+type agentStatus struct {
+	Agent      string    `json:"agent"`
+	State      string    `json:"state"`
+	PID        int       `json:"pid,omitempty"`
+	ExitCode   int       `json:"exitCode"`
+	Error      string    `json:"error,omitempty"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// headlessArgs returns the arguments that run llm on prompt without a
+// terminal. Non-interactive agents fall back to taskRunArgsTemplate.
+func headlessArgs(cfg config, llm, prompt string) ([]string, error) {
+	c := cfg.LLM.Commands[llm]
+	tmpl := c.HeadlessArgsTemplate
+	if len(tmpl) == 0 && !isInteractiveAgent(cfg, llm) {
+		tmpl = c.TaskRunArgsTemplate
+	}
+	if len(tmpl) == 0 {
+		return nil, fmt.Errorf("%s cannot run headless; set llm.commands.%s.headlessArgsTemplate", agentName(cfg, llm), llm)
+	}
+	return replaceTemplates(tmpl, map[string]string{"{task}": prompt}), nil
+}
+
+// runHeadlessAgent runs llm on prompt in the worktree of branch and waits for
+// it. Output goes to the log files in the worktree's metadata directory; the
+// returned status is also saved there.
+func runHeadlessAgent(cfg config, llm, branch, worktree, prompt string) agentStatus {
+	status := agentStatus{Agent: llm, State: agentStateRunning, StartedAt: time.Now()}
+	metaDir, err := worktreeMetaDir(worktree, branch)
+	if err != nil {
+		return failAgentStatus(status, err)
+	}
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		return saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+	}
+	args, err := headlessArgs(cfg, llm, prompt)
+	if err != nil {
+		return saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+	}
+	stdout, err := os.Create(filepath.Join(metaDir, agentLogFile))
+	if err != nil {
+		return saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+	}
+	defer stdout.Close()
+	stderr, err := os.Create(filepath.Join(metaDir, agentErrLogFile))
+	if err != nil {
+		return saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+	}
+	defer stderr.Close()
+
+	cmd := exec.Command(agentBinary(cfg, llm), args...)
+	cmd.Dir = agentDir(cfg, llm, worktree)
+	cmd.Env = append(os.Environ(), agentEnv(cfg, llm)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Start(); err != nil {
+		return saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+	}
+	status.PID = cmd.Process.Pid
+	if err := saveAgentStatus(metaDir, status); err != nil {
+		fmt.Printf("Warning: failed to save agent status: %v\n", err)
+	}
+
+	err = cmd.Wait()
+	status.ExitCode = cmd.ProcessState.ExitCode()
+	status.State = agentStateFinished
+	if err != nil {
+		status = failAgentStatus(status, err)
+	}
+	status.FinishedAt = time.Now()
+	return saveFinishedAgentStatus(metaDir, status)
+}
+
+func failAgentStatus(status agentStatus, err error) agentStatus {
+	status.State = agentStateFailed
+	status.Error = err.Error()
+	status.FinishedAt = time.Now()
+	return status
+}
+
+func saveFinishedAgentStatus(metaDir string, status agentStatus) agentStatus {
+	if err := saveAgentStatus(metaDir, status); err != nil {
+		fmt.Printf("Warning: failed to save agent status: %v\n", err)
+	}
+	return status
+}
+
+func saveAgentStatus(metaDir string, status agentStatus) error {
+	raw, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write then rename so that wtx logs -f never reads a half-written
+	// status while polling.
+	tmp, err := os.CreateTemp(metaDir, agentStatusFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(metaDir, agentStatusFile))
+}
+
+func readAgentStatus(metaDir string) (agentStatus, bool) {
+	var status agentStatus
+	raw, err := os.ReadFile(filepath.Join(metaDir, agentStatusFile))
+	if err != nil {
+		return status, false
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return status, false
+	}
+	return status, true
+}
+
+// loadAgentStatus returns the last agent run recorded for branch, or false
+// when there is none.
+func loadAgentStatus(dir, branch string) (agentStatus, bool) {
+	metaDir, err := worktreeMetaDir(dir, branch)
+	if err != nil {
+		return agentStatus{}, false
+	}
+	status, ok := readAgentStatus(metaDir)
+	if !ok {
+		return status, false
+	}
+	// A run that is gone without recording how it ended was killed.
+	if status.State == agentStateRunning && status.PID != 0 && !processAlive(status.PID) {
+		status.State = agentStateFailed
+		status.Error = "agent exited without reporting a status"
+	}
+	// A detached run whose supervisor never recorded its PID did not start.
+	if status.State == agentStateRunning && status.PID == 0 && time.Since(status.StartedAt) > agentSupervisorStartTimeout {
+		status.State = agentStateFailed
+		status.Error = "agent supervisor did not start"
+	}
+	return status, true
+}
+
+// describeAgentStatus summarizes a run for messages, e.g. "finished in 2m3s".
+func describeAgentStatus(s agentStatus) string {
+	took := s.FinishedAt.Sub(s.StartedAt).Round(time.Second)
+	switch {
+	case s.State == agentStateRunning:
+		return fmt.Sprintf("running for %s", time.Since(s.StartedAt).Round(time.Second))
+	case s.State == agentStateFinished:
+		return fmt.Sprintf("finished in %s", took)
+	case s.PID == 0:
+		return "failed to start: " + s.Error
+	case s.FinishedAt.IsZero():
+		return "failed: " + s.Error
+	default:
+		return fmt.Sprintf("failed after %s (exit %d)", took, s.ExitCode)
+	}
+}
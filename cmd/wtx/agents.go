@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// agentBinary returns the executable of an llm.commands entry, which defaults
+// to the entry's name.
+func agentBinary(cfg config, llm string) string {
+	if b := strings.TrimSpace(cfg.LLM.Commands[llm].Binary); b != "" {
+		return b
+	}
+	return llm
+}
+
+// agentName returns the name shown for an agent in messages.
+func agentName(cfg config, llm string) string {
+	if d := strings.TrimSpace(cfg.LLM.Commands[llm].DisplayName); d != "" {
+		return d
+	}
+	return llm
+}
+
+// selectAILabel is the prompt for choosing an agent, listing llm.allowed.
+func selectAILabel(cfg config, defaultValue string) string {
+	label := "Select AI (" + strings.Join(cfg.LLM.Allowed, "/") + ")"
+	if defaultValue != "" {
+		label += " [" + defaultValue + "]"
+	}
+	return label + ": "
+}
+
+// agentEnv returns the extra environment of an agent. Values may reference
+// the parent environment, e.g. "$HOME/.config/agent".
+func agentEnv(cfg config, llm string) []string {
+	env := cfg.LLM.Commands[llm].Env
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]string, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, k+"="+os.ExpandEnv(env[k]))
+	}
+	return out
+}
+
+// agentDir resolves the agent's cwd against base, the worktree it works in.
+func agentDir(cfg config, llm, base string) string {
+	cwd := strings.TrimSpace(cfg.LLM.Commands[llm].Cwd)
+	if cwd == "" {
+		return base
+	}
+	if filepath.IsAbs(cwd) {
+		return cwd
+	}
+	return filepath.Join(base, cwd)
+}
+
+// isInteractiveAgent reports whether the agent takes over the terminal when
+// running a task. Agents are interactive unless configured otherwise.
+func isInteractiveAgent(cfg config, llm string) bool {
+	v := cfg.LLM.Commands[llm].Interactive
+	return v == nil || *v
+}
+
+// runAgent runs an agent in dir with its configured binary, cwd and
+// environment. Non-interactive agents get no stdin so they cannot block on a
+// prompt. Interactive agents get the terminal, even when stdin was a pipe
+// that the task or prompt was read from.
+func runAgent(cfg config, llm, dir string, args ...string) error {
+	cmd := exec.Command(agentBinary(cfg, llm), args...)
+	cmd.Dir = agentDir(cfg, llm, dir)
+	cmd.Env = append(os.Environ(), agentEnv(cfg, llm)...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if isInteractiveAgent(cfg, llm) {
+		cmd.Stdin = os.Stdin
+		if !isInteractive() {
+			if tty, err := os.Open("/dev/tty"); err == nil {
+				defer tty.Close()
+				cmd.Stdin = tty
+			}
+		}
+	}
+	return cmd.Run()
+}
+
+// defaultAgent is llm.default when the config does not set one.
+const defaultAgent = "codex"
+
+// normalizeAgents fills in llm.allowed and llm.default: without an explicit
+// list every llm.commands entry is allowed, and the default stays codex, or
+// the only agent there is. With several agents and neither codex nor a
+// default, commands that need an agent ask for one (see agentSelectionError).
+func normalizeAgents(l *llmCfg) error {
+	if len(l.Allowed) == 0 {
+		for name := range l.Commands {
+			l.Allowed = append(l.Allowed, name)
+		}
+		sort.Strings(l.Allowed)
+	}
+	for _, a := range l.Allowed {
+		if _, ok := l.Commands[a]; !ok {
+			return fmt.Errorf("llm.allowed lists %q, which has no llm.commands entry", a)
+		}
+	}
+	if l.Default == "" {
+		switch {
+		case slices.Contains(l.Allowed, defaultAgent):
+			l.Default = defaultAgent
+		case len(l.Allowed) == 1:
+			l.Default = l.Allowed[0]
+		}
+	}
+	if l.Default != "" {
+		for _, a := range l.Allowed {
+			if strings.EqualFold(a, l.Default) {
+				return nil
+			}
+		}
+		return fmt.Errorf("llm.default %q is not in llm.allowed (%s)", l.Default, strings.Join(l.Allowed, ", "))
+	}
+	return nil
+}
+
+// agentSelectionError reports a missing or unknown agent name.
+func agentSelectionError(cfg config) error {
+	allowed := strings.Join(cfg.LLM.Allowed, ", ")
+	if cfg.LLM.Default == "" {
+		return fmt.Errorf("no valid agent selected and llm.default is not set (expected one of: %s)", allowed)
+	}
+	return fmt.Errorf("invalid AI selection (expected one of: %s)", allowed)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAgents(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		allowed  []string
+		def      string
+		want     string
+		wantErr  string
+	}{
+		{"codex stays the default", []string{"claude", "codex"}, nil, "", "codex", ""},
+		{"single agent", []string{"claude"}, nil, "", "claude", ""},
+		{"several agents without codex", []string{"claude", "gemini"}, nil, "", "", ""},
+		{"explicit default", []string{"claude", "gemini"}, nil, "gemini", "gemini", ""},
+		{"default not allowed", []string{"claude", "codex"}, []string{"claude"}, "codex", "", "not in llm.allowed"},
+		{"allowed without command", []string{"codex"}, []string{"codex", "claude"}, "", "", "no llm.commands entry"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := llmCfg{Commands: map[string]llmCommandCfg{}, Allowed: tt.allowed, Default: tt.def}
+			for _, c := range tt.commands {
+				l.Commands[c] = llmCommandCfg{}
+			}
+			err := normalizeAgents(&l)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAgents failed: %v", err)
+			}
+			if l.Default != tt.want {
+				t.Errorf("default = %q, want %q", l.Default, tt.want)
+			}
+		})
+	}
+}
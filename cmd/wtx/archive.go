@@ -0,0 +1,398 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	archiveMetaFile   = "archive.json"
+	archiveBundleFile = "branch.bundle"
+	archiveFilesFile  = "files.tar.gz"
+)
+
+type archiveMeta struct {
+	Name       string    `json:"name"`
+	Branch     string    `json:"branch"`
+	Head       string    `json:"head"`
+	Path       string    `json:"path"`
+	Bundle     bool      `json:"bundle"`
+	Files      []string  `json:"files"`
+	ArchivedAt time.Time `json:"archivedAt"`
+}
+
+// wtxDataDir returns the directory where wtx keeps its own state for the
+// repository containing dir. It lives in the shared git dir so every worktree
+// sees the same data and nothing shows up in the working tree.
+func wtxDataDir(dir string) (string, error) {
+	out, err := runCmdCapture(dir, "git", "rev-parse", "--path-format=absolute", "--git-common-dir")
+	if err != nil {
+		return "", errors.New("not inside a git repository")
+	}
+	return filepath.Join(strings.TrimSpace(out), "wtx"), nil
+}
+
+func resolveArchiveDir(cfg config, mainWorktree string) (string, error) {
+	dir := strings.TrimSpace(cfg.Archive.Dir)
+	if dir == "" {
+		data, err := wtxDataDir(mainWorktree)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(data, "archive"), nil
+	}
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, rest), nil
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(mainWorktree, dir)
+	}
+	return dir, nil
+}
+
+// archiveFilePatterns lists the untracked files worth keeping: everything
+// copyFiles puts into a worktree plus archive.files.
+func archiveFilePatterns(cfg config) []string {
+	seen := map[string]struct{}{}
+	var out []string
+	add := func(p string) {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			return
+		}
+		if _, ok := seen[p]; ok {
+			return
+		}
+		seen[p] = struct{}{}
+		out = append(out, p)
+	}
+	for _, item := range cfg.CopyFiles {
+		if strings.TrimSpace(item.To) != "" {
+			add(item.To)
+		} else {
+			add(item.From)
+		}
+	}
+	for _, p := range cfg.Archive.Files {
+		add(p)
+	}
+	return out
+}
+
+// archiveWorktree saves branch as a git bundle and the configured files of the
+// worktree at path as a tarball, so the worktree can be brought back with
+// wtx restore after it has been removed.
+func archiveWorktree(cfg config, mainWorktree, path, branch string) (string, error) {
+	root, err := resolveArchiveDir(cfg, mainWorktree)
+	if err != nil {
+		return "", err
+	}
+	headRaw, err := runCmdCapture(mainWorktree, "git", "rev-parse", "refs/heads/"+branch)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve branch %s: %s", branch, strings.TrimSpace(headRaw))
+	}
+
+	name := strings.ReplaceAll(branch, "/", "__") + "-" + time.Now().Format("20060102-150405")
+	dir := filepath.Join(root, name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	meta := archiveMeta{
+		Name:       name,
+		Branch:     branch,
+		Head:       strings.TrimSpace(headRaw),
+		Path:       path,
+		ArchivedAt: time.Now(),
+	}
+
+	// Commits already in the main branch stay in the repository, so only the
+	// branch's own commits go into the bundle. Git refuses to write an empty
+	// bundle; the recorded head is enough to restore in that case.
+	bundlePath := filepath.Join(dir, archiveBundleFile)
+	if runCmdIn(mainWorktree, "git", "bundle", "create", bundlePath, "refs/heads/"+branch, "^"+cfg.MainBranch) == nil {
+		meta.Bundle = true
+	} else {
+		_ = os.Remove(bundlePath)
+	}
+
+	if isDir(path) {
+		files, err := writeArchiveFiles(filepath.Join(dir, archiveFilesFile), path, archiveFilePatterns(cfg))
+		if err != nil {
+			return "", err
+		}
+		meta.Files = files
+	}
+
+	raw, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(dir, archiveMetaFile), raw, 0o644); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// writeArchiveFiles writes every file matching patterns (relative to root,
+// globs and directories allowed) into a gzipped tarball at dst.
+func writeArchiveFiles(dst, root string, patterns []string) ([]string, error) {
+	var files []string
+	seen := map[string]struct{}{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive file pattern %q: %w", pattern, err)
+		}
+		for _, m := range matches {
+			err := filepath.WalkDir(m, func(p string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return err
+				}
+				rel, err := filepath.Rel(root, p)
+				if err != nil {
+					return err
+				}
+				if _, ok := seen[rel]; !ok {
+					seen[rel] = struct{}{}
+					files = append(files, rel)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	sort.Strings(files)
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for _, rel := range files {
+		if err := addTarFile(tw, filepath.Join(root, rel), rel); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return files, f.Close()
+}
+
+func addTarFile(tw *tar.Writer, src, name string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(name)
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = io.Copy(tw, in)
+	return err
+}
+
+func extractArchiveFiles(src, root string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		rel := filepath.FromSlash(hdr.Name)
+		if !filepath.IsLocal(rel) {
+			return fmt.Errorf("refusing to extract %s outside the worktree", hdr.Name)
+		}
+		dst := filepath.Join(root, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+		out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return err
+		}
+		if err := out.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Restored file: %s\n", hdr.Name)
+	}
+}
+
+func listArchives(dir string) ([]archiveMeta, error) {
+	items, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []archiveMeta
+	for _, item := range items {
+		if !item.IsDir() {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, item.Name(), archiveMetaFile))
+		if err != nil {
+			continue
+		}
+		var meta archiveMeta
+		if err := json.Unmarshal(raw, &meta); err != nil {
+			continue
+		}
+		meta.Name = item.Name()
+		out = append(out, meta)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ArchivedAt.After(out[j].ArchivedAt) })
+	return out, nil
+}
+
+// selectArchive picks an archive by index, archive name or branch name. A
+// branch name selects its most recent archive.
+func selectArchive(archives []archiveMeta, args []string) (archiveMeta, error) {
+	if len(args) > 0 {
+		target := strings.TrimSpace(args[0])
+		if idx, err := strconv.Atoi(target); err == nil {
+			if idx >= 1 && idx <= len(archives) {
+				return archives[idx-1], nil
+			}
+			return archiveMeta{}, fmt.Errorf("invalid index: %d", idx)
+		}
+		for _, a := range archives {
+			if target == a.Name || target == a.Branch {
+				return a, nil
+			}
+		}
+		return archiveMeta{}, fmt.Errorf("archive not found: %s", target)
+	}
+
+	fmt.Println("Select an archive:")
+	for i, a := range archives {
+		fmt.Printf("  %d) %s (%s, archived %s)\n", i+1, a.Name, a.Branch, a.ArchivedAt.Format("2006-01-02 15:04"))
+	}
+	in := promptOptional("Enter number, archive name or branch: ")
+	if in == "" {
+		return archiveMeta{}, errors.New("no selection provided")
+	}
+	return selectArchive(archives, []string{in})
+}
+
+func runRestore(cfg config, args []string) error {
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return errors.New("no worktrees found")
+	}
+	mainWorktree := entries[0].path
+
+	dir, err := resolveArchiveDir(cfg, mainWorktree)
+	if err != nil {
+		return err
+	}
+	archives, err := listArchives(dir)
+	if err != nil {
+		return err
+	}
+	if len(archives) == 0 {
+		return fmt.Errorf("no archives found in %s", dir)
+	}
+	a, err := selectArchive(archives, args)
+	if err != nil {
+		return err
+	}
+	archivePath := filepath.Join(dir, a.Name)
+
+	targetPath := filepath.Join(mainWorktree, cfg.WorktreesDir, strings.ReplaceAll(a.Branch, "/", "__"))
+	if _, err := os.Stat(targetPath); err == nil {
+		return fmt.Errorf("target path already exists: %s", targetPath)
+	}
+
+	localRef := "refs/heads/" + a.Branch
+	if runCmdIn(mainWorktree, "git", "show-ref", "--verify", "--quiet", localRef) == nil {
+		fmt.Printf("Branch '%s' already exists. Using it as is.\n", a.Branch)
+	} else {
+		if a.Bundle {
+			fmt.Printf("Fetching '%s' from archive bundle...\n", a.Branch)
+			if err := runCmdStream(mainWorktree, "git", "fetch", filepath.Join(archivePath, archiveBundleFile), localRef); err != nil {
+				return err
+			}
+		}
+		if err := runCmdStream(mainWorktree, "git", "branch", a.Branch, a.Head); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return err
+	}
+	if err := runCmdStream(mainWorktree, "git", "worktree", "add", targetPath, a.Branch); err != nil {
+		return err
+	}
+
+	filesPath := filepath.Join(archivePath, archiveFilesFile)
+	if _, err := os.Stat(filesPath); err == nil {
+		if err := extractArchiveFiles(filesPath, targetPath); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Worktree restored at: %s\n", targetPath)
+	fmt.Printf("Branch: %s (%s)\n", a.Branch, a.Head)
+	return nil
+}
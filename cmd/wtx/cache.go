@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// branchNameCacheTTL is how long AI branch name answers are reused.
+const branchNameCacheTTL = 7 * 24 * time.Hour
+
+type branchNameCacheEntry struct {
+	CreatedAt   time.Time          `json:"createdAt"`
+	Suggestions []branchSuggestion `json:"suggestions"`
+}
+
+// branchNameCacheKey identifies an AI call by the CLI and its arguments, which
+// include the prompt and therefore the task text.
+func branchNameCacheKey(llm string, args []string) string {
+	sum := sha256.Sum256([]byte(llm + "\x00" + strings.Join(args, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func branchNameCachePath(key string) (string, error) {
+	data, err := wtxDataDir("")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "cache", "branch-names", key+".json"), nil
+}
+
+func loadCachedBranchNames(key string) ([]branchSuggestion, bool) {
+	p, err := branchNameCachePath(key)
+	if err != nil {
+		return nil, false
+	}
+	raw, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	var entry branchNameCacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil || len(entry.Suggestions) == 0 {
+		return nil, false
+	}
+	if time.Since(entry.CreatedAt) > branchNameCacheTTL {
+		_ = os.Remove(p)
+		return nil, false
+	}
+	return entry.Suggestions, true
+}
+
+func saveCachedBranchNames(key string, suggestions []branchSuggestion) error {
+	p, err := branchNameCachePath(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(branchNameCacheEntry{
+		CreatedAt:   time.Now(),
+		Suggestions: suggestions,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(p, raw, 0o644)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Values of the branchCollision config key and --on-collision flag.
+const (
+	collisionPrompt = "prompt"
+	collisionSuffix = "suffix"
+	collisionReuse  = "reuse"
+)
+
+// branchCollision records where a proposed branch name is already taken.
+type branchCollision struct {
+	local    bool
+	remote   bool
+	worktree string
+	path     bool
+}
+
+func (c branchCollision) any() bool {
+	return c.local || c.remote || c.worktree != "" || c.path
+}
+
+func (c branchCollision) describe(cfg config, branch, targetPath string) string {
+	var parts []string
+	if c.local {
+		parts = append(parts, "local branch '"+branch+"'")
+	}
+	if c.remote {
+		parts = append(parts, "remote branch '"+cfg.PushRemote+"/"+branch+"'")
+	}
+	if c.worktree != "" {
+		parts = append(parts, "worktree at '"+c.worktree+"'")
+	}
+	if c.path {
+		parts = append(parts, "existing path '"+targetPath+"'")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func validateCollisionPolicy(policy string) error {
+	switch policy {
+	case collisionPrompt, collisionSuffix, collisionReuse:
+		return nil
+	}
+	return fmt.Errorf("invalid branch collision policy: %q (expected one of: %s, %s, %s)", policy, collisionPrompt, collisionSuffix, collisionReuse)
+}
+
+// collisionPolicy returns the --on-collision flag value, falling back to the
+// branchCollision config.
+func collisionPolicy(cfg config, flags flagValues) (string, error) {
+	if !flags.has("on-collision") {
+		return cfg.BranchCollision, nil
+	}
+	policy := strings.TrimSpace(flags.value("on-collision"))
+	return policy, validateCollisionPolicy(policy)
+}
+
+func worktreeTargetPath(worktreesDir, branch string) string {
+	return filepath.Join(worktreesDir, strings.ReplaceAll(branch, "/", "__"))
+}
+
+func detectBranchCollision(cfg config, entries []worktreeEntry, worktreesDir, branch string) branchCollision {
+	var c branchCollision
+	c.local = runCmd("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch) == nil
+	c.remote = runCmd("git", "ls-remote", "--exit-code", "--heads", cfg.PushRemote, branch) == nil
+	for _, e := range entries {
+		if strings.TrimPrefix(e.branch, "refs/heads/") == branch {
+			c.worktree = e.path
+		}
+	}
+	targetPath := worktreeTargetPath(worktreesDir, branch)
+	if _, err := os.Stat(targetPath); err == nil && c.worktree != targetPath {
+		c.path = true
+	}
+	return c
+}
+
+// resolveBranchCollision applies policy when branch is already taken. It
+// returns the branch to use and, when reusing, the path of the worktree that
+// already has it checked out. Every outcome is reported before anything is
+// created or pushed.
+func resolveBranchCollision(cfg config, policy, worktreesDir, branch string) (string, string, error) {
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return "", "", err
+	}
+	entries := parseWorktreeList(listRaw)
+
+	c := detectBranchCollision(cfg, entries, worktreesDir, branch)
+	if !c.any() {
+		return branch, "", nil
+	}
+	fmt.Printf("Branch name '%s' is already taken by: %s\n", branch, c.describe(cfg, branch, worktreeTargetPath(worktreesDir, branch)))
+
+	if policy == collisionPrompt {
+		if !isInteractive() {
+			return "", "", errors.New("branch name collision in non-interactive mode; set branchCollision or pass --on-collision suffix|reuse")
+		}
+		switch strings.ToLower(promptOptional("Reuse it, add a suffix, or abort? [r/s/A]: ")) {
+		case "r", "reuse":
+			policy = collisionReuse
+		case "s", "suffix":
+			policy = collisionSuffix
+		default:
+			return "", "", errors.New("aborted")
+		}
+	}
+
+	if policy == collisionReuse {
+		if c.path {
+			return "", "", fmt.Errorf("cannot reuse '%s': target path exists and is not its worktree", branch)
+		}
+		if c.worktree != "" {
+			fmt.Printf("Reusing existing worktree: %s\n", c.worktree)
+		} else {
+			fmt.Printf("Reusing existing branch '%s'.\n", branch)
+		}
+		return branch, c.worktree, nil
+	}
+
+	for n := 2; ; n++ {
+		candidate := branch + "-" + strconv.Itoa(n)
+		if !detectBranchCollision(cfg, entries, worktreesDir, candidate).any() {
+			fmt.Printf("Using branch name '%s' instead.\n", candidate)
+			return candidate, "", nil
+		}
+	}
+}
+
+// isInteractive reports whether stdin is a terminal, so prompts can be shown.
+func isInteractive() bool {
+	return isTerminal(os.Stdin)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
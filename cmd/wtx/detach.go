@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// agentSupervisorCmd is the hidden subcommand a detached run re-executes wtx
+// with; it runs the agent and records how it ended.
+const agentSupervisorCmd = "__agent"
+
+// agentSupervisorStartTimeout is how long a detached run may stay without a
+// recorded supervisor PID before it is reported as not started.
+const agentSupervisorStartTimeout = time.Minute
+
+// detachLLMTask starts the agent for a new worktree in the background.
+func detachLLMTask(cfg config, llm, branch, worktree, prompt string) error {
+	if !commandExists(agentBinary(cfg, llm)) {
+		fmt.Printf("%s not found. Skip auto-run.\n", agentBinary(cfg, llm))
+		return nil
+	}
+	// A reused worktree may still have an agent at work.
+	if s, ok := loadAgentStatus(worktree, branch); ok && s.State == agentStateRunning {
+		return fmt.Errorf("%s is already running in %s (see wtx logs %s)", agentName(cfg, s.Agent), worktree, branch)
+	}
+	fmt.Printf("Starting %s in the background...\n", agentName(cfg, llm))
+	if err := startDetachedAgent(cfg, llm, branch, worktree, prompt); err != nil {
+		return err
+	}
+	fmt.Printf("Follow it with: wtx logs -f %s\n", branch)
+	return nil
+}
+
+// startDetachedAgent runs llm on prompt in the background, outliving this
+// process. The transcript and status end up in the worktree's metadata
+// directory (see wtx list and wtx logs).
+func startDetachedAgent(cfg config, llm, branch, worktree, prompt string) error {
+	metaDir, err := worktreeMetaDir(worktree, branch)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(metaDir, agentPromptFile), []byte(prompt), 0o644); err != nil {
+		return err
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	configPath, err := filepath.Abs(resolveConfigPath())
+	if err != nil {
+		return err
+	}
+	devNull, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer devNull.Close()
+
+	// Record the run before starting it so a quick agent cannot finish
+	// before its running state is written.
+	status := agentStatus{Agent: llm, State: agentStateRunning, StartedAt: time.Now()}
+	if err := saveAgentStatus(metaDir, status); err != nil {
+		return err
+	}
+	cmd := exec.Command(self, agentSupervisorCmd, llm, branch, worktree)
+	cmd.Env = append(os.Environ(), "WTX_CONFIG_PATH="+configPath)
+	cmd.Stdin = devNull
+	cmd.Stdout = devNull
+	cmd.Stderr = devNull
+	detachProcess(cmd)
+	if err := cmd.Start(); err != nil {
+		saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+		return err
+	}
+	// From here on only the supervisor writes the status; it records its
+	// own PID first.
+	return cmd.Process.Release()
+}
+
+// runAgentSupervisor is the background half of startDetachedAgent.
+func runAgentSupervisor(cfg config, args []string) error {
+	if len(args) != 3 {
+		return errors.New("usage: wtx " + agentSupervisorCmd + " <agent> <branch> <worktree>")
+	}
+	llm, branch, worktree := args[0], args[1], args[2]
+	metaDir, err := worktreeMetaDir(worktree, branch)
+	if err != nil {
+		return err
+	}
+	// Record this process so that dying before the agent starts is noticed.
+	status := agentStatus{Agent: llm, State: agentStateRunning, PID: os.Getpid(), StartedAt: time.Now()}
+	if err := saveAgentStatus(metaDir, status); err != nil {
+		return err
+	}
+	prompt, err := os.ReadFile(filepath.Join(metaDir, agentPromptFile))
+	if err != nil {
+		failAgentSupervisor(args, err)
+		return err
+	}
+	status = runHeadlessAgent(cfg, llm, branch, worktree, string(prompt))
+	if status.State != agentStateFinished {
+		return fmt.Errorf("%s %s", agentName(cfg, llm), describeAgentStatus(status))
+	}
+	return nil
+}
+
+// failAgentSupervisor records that the agent of a supervisor's run could not
+// be started, e.g. because the config or the prompt failed to load.
+func failAgentSupervisor(args []string, err error) {
+	if len(args) != 3 {
+		return
+	}
+	metaDir, mdErr := worktreeMetaDir(args[2], args[1])
+	if mdErr != nil {
+		return
+	}
+	status := agentStatus{Agent: args[0], StartedAt: time.Now()}
+	saveFinishedAgentStatus(metaDir, failAgentStatus(status, err))
+}
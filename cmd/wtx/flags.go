@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flagValues holds parsed flags keyed by name without leading dashes. Boolean
+// flags are stored with an empty value.
+type flagValues map[string]string
+
+func (f flagValues) has(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+func (f flagValues) value(name string) string {
+	return f[name]
+}
+
+// parseFlags separates flags from positional arguments so flags may appear
+// anywhere after the subcommand. boolFlags take no value; valueFlags take the
+// next argument or an inline "--name=value". A lone "--" ends flag parsing and
+// a lone "-" is kept as a positional argument.
+func parseFlags(args []string, boolFlags, valueFlags []string) ([]string, flagValues, error) {
+	isBool := map[string]bool{}
+	for _, n := range boolFlags {
+		isBool[n] = true
+	}
+	isValue := map[string]bool{}
+	for _, n := range valueFlags {
+		isValue[n] = true
+	}
+
+	var positional []string
+	flags := flagValues{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if a == "-" || !strings.HasPrefix(a, "-") {
+			positional = append(positional, a)
+			continue
+		}
+
+		name := strings.TrimLeft(a, "-")
+		value := ""
+		hasValue := false
+		if k, v, ok := strings.Cut(name, "="); ok {
+			name, value, hasValue = k, v, true
+		}
+		switch {
+		case isBool[name]:
+			if hasValue {
+				return nil, nil, fmt.Errorf("flag --%s does not take a value", name)
+			}
+			flags[name] = ""
+		case isValue[name]:
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("flag --%s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			flags[name] = value
+		default:
+			return nil, nil, fmt.Errorf("unknown flag: %s", a)
+		}
+	}
+	return positional, flags, nil
+}
+
+// parseAge parses durations such as "30d", "2w" or "36h". Days and weeks are
+// accepted in addition to the units understood by time.ParseDuration.
+func parseAge(v string) (time.Duration, error) {
+	v = strings.TrimSpace(v)
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(v, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age: %s", v)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s (examples: 30d, 2w, 36h)", v)
+	}
+	return d, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	boolFlags := []string{"yes", "y", "dry-run"}
+	valueFlags := []string{"issue", "base-remote"}
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		flags   flagValues
+		wantErr bool
+	}{
+		{"empty", nil, nil, flagValues{}, false},
+		{"positional only", []string{"task", "develop"}, []string{"task", "develop"}, flagValues{}, false},
+		{"flags anywhere", []string{"--yes", "task", "--issue", "PROJ-1", "develop"}, []string{"task", "develop"}, flagValues{"yes": "", "issue": "PROJ-1"}, false},
+		{"inline value", []string{"task", "--issue=#12"}, []string{"task"}, flagValues{"issue": "#12"}, false},
+		{"single dash", []string{"-y", "task"}, []string{"task"}, flagValues{"y": ""}, false},
+		{"stdin argument", []string{"-", "--dry-run"}, []string{"-"}, flagValues{"dry-run": ""}, false},
+		{"end of flags", []string{"--yes", "--", "--issue", "x"}, []string{"--issue", "x"}, flagValues{"yes": ""}, false},
+		{"value looks like a flag", []string{"--base-remote", "--yes"}, nil, flagValues{"base-remote": "--yes"}, false},
+		{"unknown flag", []string{"--nope"}, nil, nil, true},
+		{"missing value", []string{"task", "--issue"}, nil, nil, true},
+		{"bool with value", []string{"--yes=1"}, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, flags, err := parseFlags(tt.args, boolFlags, valueFlags)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseFlags(%q) succeeded, want an error", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseFlags(%q) failed: %v", tt.args, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("positional = %q, want %q", got, tt.want)
+			}
+			if !reflect.DeepEqual(flags, tt.flags) {
+				t.Errorf("flags = %v, want %v", flags, tt.flags)
+			}
+		})
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{" 90m ", 90 * time.Minute, false},
+		{"0d", 0, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"-1d", 0, true},
+		{"-5h", 0, true},
+		{"1.5d", 0, true},
+		{"ten days", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseAge(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAge(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseAge(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type issueInfo struct {
+	Number int    `json:"number"`
+	Title  string `json:"title"`
+	Body   string `json:"body"`
+	URL    string `json:"url"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+}
+
+// runIssue creates a worktree for a GitHub issue, using its title and body as
+// the task and its labels to pick the branch prefix.
+func runIssue(cfg config, args []string) error {
+	args, flags, err := parseFlags(args, []string{"no-push", "no-ai", "yes", "y", "detach"}, append([]string{"on-collision", "template"}, remoteFlags...))
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	onCollision, err := collisionPolicy(cfg, flags)
+	if err != nil {
+		return err
+	}
+	template, err := promptTemplateName(cfg, flags)
+	if err != nil {
+		return err
+	}
+	if err := requireCmd("gh"); err != nil {
+		return err
+	}
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return errors.New("issue number or URL is required (example: wtx issue 123)")
+	}
+
+	base := cfg.DefaultBaseBranch
+	llm := cfg.LLM.Default
+	if len(args) >= 2 && strings.TrimSpace(args[1]) != "" {
+		base = args[1]
+	}
+	if len(args) >= 3 {
+		llm = args[2]
+	}
+	llm = normalizeLLM(cfg, llm)
+	if llm == "" {
+		return agentSelectionError(cfg)
+	}
+
+	issue, err := viewIssue(cfg, strings.TrimPrefix(strings.TrimSpace(args[0]), "#"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Issue #%d: %s\n", issue.Number, issue.Title)
+
+	labels := make([]string, 0, len(issue.Labels))
+	for _, l := range issue.Labels {
+		labels = append(labels, l.Name)
+	}
+	task := issue.Title
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		task += "\n\n" + body
+	}
+
+	_, err = createWorktree(cfg, worktreeOptions{
+		task:          task,
+		base:          base,
+		llm:           llm,
+		initialPrompt: task + "\n\nIssue: " + issue.URL,
+		runTask:       !flags.has("no-ai"),
+		push:          cfg.Push == pushOnCreate && !flags.has("no-push"),
+		onCollision:   onCollision,
+		issue:         strconv.Itoa(issue.Number),
+		issueURL:      issue.URL,
+		prefix:        labelPrefix(cfg.BranchNaming, labels),
+		yes:           flags.has("yes") || flags.has("y"),
+		template:      template,
+		issueBody:     strings.TrimSpace(issue.Body),
+		detach:        flags.has("detach"),
+	})
+	return err
+}
+
+func viewIssue(cfg config, ref string) (issueInfo, error) {
+	var issue issueInfo
+	args := []string{"issue", "view", ref, "--json", "number,title,body,labels,url"}
+	// A URL already names its repository.
+	if !strings.Contains(ref, "://") {
+		args = append(args, ghRepoArgs(cfg)...)
+	}
+	out, err := runCmdCapture("", "gh", args...)
+	if err != nil {
+		return issue, fmt.Errorf("failed to load issue %s: %s", ref, strings.TrimSpace(out))
+	}
+	if err := json.Unmarshal([]byte(out), &issue); err != nil {
+		return issue, fmt.Errorf("failed to parse gh output: %w", err)
+	}
+	return issue, nil
+}
+
+// closingIssueRef returns the "Closes #N" line for the GitHub issue recorded
+// for branch, or "" when there is none. Issue-tracker keys such as PROJ-123
+// are not GitHub issues and are left alone.
+func closingIssueRef(branch string) string {
+	meta, ok := loadWorktreeMeta("", branch)
+	if !ok || meta.Issue == "" {
+		return ""
+	}
+	if _, err := strconv.Atoi(meta.Issue); err != nil {
+		return ""
+	}
+	return "Closes #" + meta.Issue
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// runList prints the worktrees with the state of their last headless agent
+// run, if any.
+func runList(cfg config) error {
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+
+	width := 0
+	for _, e := range entries {
+		width = max(width, len(worktreeLabel(e)))
+	}
+	for i, e := range entries {
+		line := fmt.Sprintf("%2d) %-*s  %s", i+1, width, worktreeLabel(e), e.path)
+		branch := strings.TrimPrefix(e.branch, "refs/heads/")
+		if branch != "" {
+			if s, ok := loadAgentStatus(e.path, branch); ok {
+				line += fmt.Sprintf("  [%s %s]", agentName(cfg, s.Agent), describeAgentStatus(s))
+			}
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+func worktreeLabel(e worktreeEntry) string {
+	label := strings.TrimPrefix(e.branch, "refs/heads/")
+	if label == "" {
+		label = "(detached)"
+	}
+	if e.locked {
+		label += " [locked]"
+	}
+	return label
+}
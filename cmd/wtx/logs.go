@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// logsPollInterval is how often wtx logs -f checks for new output.
+const logsPollInterval = 500 * time.Millisecond
+
+// runLogs prints the transcript of a worktree's last headless agent run;
+// -f keeps printing new output until the agent exits.
+func runLogs(cfg config, args []string) error {
+	positional, flags, err := parseFlags(args, []string{"f", "follow", "stderr"}, nil)
+	if err != nil {
+		return err
+	}
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return errors.New("no worktrees found")
+	}
+	selected, err := selectWorktree(entries, positional)
+	if err != nil {
+		return err
+	}
+	branch := strings.TrimPrefix(selected.branch, "refs/heads/")
+	if branch == "" {
+		return fmt.Errorf("worktree has no branch: %s", selected.path)
+	}
+
+	metaDir, err := worktreeMetaDir(selected.path, branch)
+	if err != nil {
+		return err
+	}
+	name := agentLogFile
+	if flags.has("stderr") {
+		name = agentErrLogFile
+	}
+	f, err := os.Open(filepath.Join(metaDir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no agent transcript for %s", branch)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(os.Stdout, f); err != nil {
+		return err
+	}
+	if !flags.has("f") && !flags.has("follow") {
+		return nil
+	}
+	for {
+		// Check the state first so output written just before the agent
+		// exited is still copied.
+		status, ok := loadAgentStatus(selected.path, branch)
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			return err
+		}
+		if !ok || status.State != agentStateRunning {
+			if ok {
+				fmt.Fprintf(os.Stderr, "%s %s\n", agentName(cfg, status.Agent), describeAgentStatus(status))
+			}
+			return nil
+		}
+		time.Sleep(logsPollInterval)
+	}
+}
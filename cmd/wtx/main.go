@@ -3,28 +3,57 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
 var version = "dev"
 
+// stdinReader is shared by all prompts so buffered input is not lost between
+// them when stdin is a pipe.
+var stdinReader = bufio.NewReader(os.Stdin)
+
 type config struct {
-	MainBranch        string           `json:"mainBranch"`
-	DefaultBaseBranch string           `json:"defaultBaseBranch"`
-	WorktreesDir      string           `json:"worktreesDir"`
-	CopyFiles         []copyFileConfig `json:"copyFiles"`
-	PostCreateHooks   []hookConfig     `json:"postCreateHooks"`
-	LLM               llmCfg           `json:"llm"`
+	MainBranch           string           `json:"mainBranch"`
+	DefaultBaseBranch    string           `json:"defaultBaseBranch"`
+	WorktreesDir         string           `json:"worktreesDir"`
+	BaseRemote           string           `json:"baseRemote"`
+	PushRemote           string           `json:"pushRemote"`
+	Push                 string           `json:"push"`
+	BranchCollision      string           `json:"branchCollision"`
+	BranchNaming         branchNamingCfg  `json:"branchNaming"`
+	CoWorktree           bool             `json:"coWorktree"`
+	CopyFiles            []copyFileConfig `json:"copyFiles"`
+	PostCreateHooks      []hookConfig     `json:"postCreateHooks"`
+	PreRemoveHooks       []hookConfig     `json:"preRemoveHooks"`
+	PostRemoveHooks      []hookConfig     `json:"postRemoveHooks"`
+	RaceChecks           []hookConfig     `json:"raceChecks"`
+	MergeStrategies      []string         `json:"mergeStrategies"`
+	ProtectedBranches    []string         `json:"protectedBranches"`
+	DeleteRemoteBranches bool             `json:"deleteRemoteBranches"`
+	Archive              archiveCfg       `json:"archive"`
+	LLM                  llmCfg           `json:"llm"`
+}
+
+type archiveCfg struct {
+	Enabled bool     `json:"enabled"`
+	Dir     string   `json:"dir"`
+	Files   []string `json:"files"`
 }
 
 type copyFileConfig struct {
@@ -40,31 +69,98 @@ type hookConfig struct {
 }
 
 type llmCfg struct {
-	Default                  string                   `json:"default"`
-	Allowed                  []string                 `json:"allowed"`
-	BranchNamePromptTemplate string                   `json:"branchNamePromptTemplate"`
-	Commands                 map[string]llmCommandCfg `json:"commands"`
+	Default                      string                       `json:"default"`
+	Allowed                      []string                     `json:"allowed"`
+	BranchNamePromptTemplate     string                       `json:"branchNamePromptTemplate"`
+	BranchNameFormat             string                       `json:"branchNameFormat"`
+	BranchNameJSONPromptTemplate string                       `json:"branchNameJsonPromptTemplate"`
+	BranchNameTimeout            string                       `json:"branchNameTimeout"`
+	BranchNameProvider           string                       `json:"branchNameProvider"`
+	TaskEditorTemplate           string                       `json:"taskEditorTemplate"`
+	Providers                    map[string]llmProviderCfg    `json:"providers"`
+	ReviewPromptTemplate         string                       `json:"reviewPromptTemplate"`
+	PromptTemplates              map[string]promptTemplateCfg `json:"promptTemplates"`
+	DefaultPromptTemplate        string                       `json:"defaultPromptTemplate"`
+	Commands                     map[string]llmCommandCfg     `json:"commands"`
 }
 
+// llmCommandCfg describes an agent CLI. Only the key and the args templates
+// are required; binary defaults to the key.
 type llmCommandCfg struct {
-	BranchNameArgsTemplate []string `json:"branchNameArgsTemplate"`
-	TaskRunArgsTemplate    []string `json:"taskRunArgsTemplate"`
+	DisplayName                string            `json:"displayName"`
+	Binary                     string            `json:"binary"`
+	Env                        map[string]string `json:"env"`
+	Cwd                        string            `json:"cwd"`
+	Interactive                *bool             `json:"interactive"`
+	BranchNameArgsTemplate     []string          `json:"branchNameArgsTemplate"`
+	BranchNameJSONArgsTemplate []string          `json:"branchNameJsonArgsTemplate"`
+	TaskRunArgsTemplate        []string          `json:"taskRunArgsTemplate"`
+	HeadlessArgsTemplate       []string          `json:"headlessArgsTemplate"`
+}
+
+// Values of the push config key.
+const (
+	pushNever    = "never"
+	pushOnCreate = "onCreate"
+	pushOnPR     = "onPR"
+)
+
+// worktreeOptions are the inputs of createWorktree.
+type worktreeOptions struct {
+	task          string
+	base          string
+	llm           string
+	initialPrompt string
+	runTask       bool
+	push          bool
+	// from is the start point of the new branch instead of the base remote's
+	// base branch: a branch, tag, commit or "pr:<number>".
+	from string
+	// existing names a local branch to check out instead of creating one.
+	existing string
+	// onCollision is the policy for a generated name that is already taken.
+	onCollision string
+	// issue is the issue key for the branch name; detected from the task
+	// when empty.
+	issue    string
+	issueURL string
+	// prefix replaces the branch type chosen by the AI, e.g. "fix/" for
+	// issues labelled as bugs.
+	prefix string
+	// yes skips the branch name confirmation.
+	yes bool
+	// naming is an already chosen branch name; the name is generated from
+	// the task when nil.
+	naming *branchSuggestion
+	// template names the llm.promptTemplates entry the initial prompt is
+	// rendered with; empty sends the prompt as is.
+	template string
+	// issueBody is the already loaded body of the issue, if any.
+	issueBody string
+	// detach runs the agent headlessly in the background instead of in the
+	// terminal.
+	detach bool
 }
 
 type worktreeEntry struct {
-	path   string
-	branch string
+	path       string
+	branch     string
+	locked     bool
+	lockReason string
 }
 
 func main() {
 	configPath := resolveConfigPath()
 	cfg, err := loadConfig(configPath)
 	if err != nil {
+		if len(os.Args) > 1 && os.Args[1] == agentSupervisorCmd {
+			failAgentSupervisor(os.Args[2:], err)
+		}
 		fatal(err)
 	}
 
 	if len(os.Args) < 2 {
-		fatal(errors.New("usage: wtx <start|new|nw|clean|switch|cd|code|co|rco|propen|version> [args...]"))
+		fatal(errors.New("usage: wtx <start|new|nw|rm|clean|restore|lock|unlock|switch|cd|code|co|rco|propen|review|issue|race|list|logs|version> [args...]"))
 	}
 
 	sub := os.Args[1]
@@ -75,8 +171,16 @@ func main() {
 		err = runStart(cfg, args)
 	case "new", "nw":
 		err = runNewWorktree(cfg, args, true)
+	case "rm":
+		err = runRemove(cfg, args)
 	case "clean":
-		err = runClean(cfg)
+		err = runClean(cfg, args)
+	case "restore":
+		err = runRestore(cfg, args)
+	case "lock":
+		err = runLock(args)
+	case "unlock":
+		err = runUnlock(args)
 	case "switch":
 		err = runSwitch(args)
 	case "cd":
@@ -84,9 +188,21 @@ func main() {
 	case "code":
 		err = runCode(args)
 	case "co", "rco":
-		err = runRemoteCheckout(args)
+		err = runRemoteCheckout(cfg, args)
 	case "propen":
-		err = runPROpen(args)
+		err = runPROpen(cfg, args)
+	case "review":
+		err = runReview(cfg, args)
+	case "issue":
+		err = runIssue(cfg, args)
+	case "race":
+		err = runRace(cfg, args)
+	case "list", "ls":
+		err = runList(cfg)
+	case "logs":
+		err = runLogs(cfg, args)
+	case agentSupervisorCmd:
+		err = runAgentSupervisor(cfg, args)
 	case "version":
 		fmt.Println(resolveVersion())
 		return
@@ -99,6 +215,20 @@ func main() {
 }
 
 func runStart(cfg config, args []string) error {
+	args, flags, err := parseFlags(args, []string{"no-push", "yes", "y", "edit", "detach"}, append([]string{"on-collision", "issue", "prompt-file", "template"}, remoteFlags...))
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	onCollision, err := collisionPolicy(cfg, flags)
+	if err != nil {
+		return err
+	}
+	template, err := promptTemplateName(cfg, flags)
+	if err != nil {
+		return err
+	}
+
 	var task string
 	base := cfg.DefaultBaseBranch
 	llm := ""
@@ -106,15 +236,23 @@ func runStart(cfg config, args []string) error {
 
 	switch len(args) {
 	case 0:
-		task = promptRequired("Task description: ")
-		base = promptBaseBranch(cfg.DefaultBaseBranch)
-		llm = promptOptional("Select AI (codex/claude): ")
+		if !flags.has("edit") {
+			if task, err = promptTask(cfg); err != nil {
+				return err
+			}
+		}
+		base = promptBaseBranch(cfg.BaseRemote, cfg.DefaultBaseBranch)
+		llm = promptOptional(selectAILabel(cfg, ""))
 	case 1:
 		v := strings.ToLower(strings.TrimSpace(args[0]))
 		if isAllowedLLM(cfg, v) {
 			llm = v
-			task = promptRequired("Task description: ")
-			base = promptBaseBranch(cfg.DefaultBaseBranch)
+			if !flags.has("edit") {
+				if task, err = promptTask(cfg); err != nil {
+					return err
+				}
+			}
+			base = promptBaseBranch(cfg.BaseRemote, cfg.DefaultBaseBranch)
 		} else {
 			task = args[0]
 		}
@@ -127,31 +265,85 @@ func runStart(cfg config, args []string) error {
 		llm = args[2]
 	}
 
+	if task == "-" && flags.value("prompt-file") == "-" {
+		return errors.New("stdin cannot be both the task and the --prompt-file")
+	}
+	// With the task or prompt on stdin there is nothing left to answer
+	// prompts with.
+	stdinUsed := task == "-" || flags.value("prompt-file") == "-"
+	if task, err = readTextArg(task); err != nil {
+		return err
+	}
+	if flags.has("edit") {
+		if task, err = editTask(cfg, task); err != nil {
+			return err
+		}
+	}
 	if strings.TrimSpace(task) == "" {
 		return errors.New("no description provided")
 	}
 	if strings.TrimSpace(base) == "" {
 		base = cfg.DefaultBaseBranch
 	}
+	issue := flags.value("issue")
+	if cfg.BranchNaming.RequireIssue && !stdinUsed && normalizeIssue(issue) == "" && detectIssue(cfg.BranchNaming, task) == "" {
+		issue = promptRequired("Issue key (e.g. PROJ-123): ")
+	}
 
 	llm = normalizeLLM(cfg, llm)
+	if llm == "" && stdinUsed {
+		llm = cfg.LLM.Default
+	}
 	if llm == "" {
-		llm = normalizeLLM(cfg, promptOptional("Select AI (codex/claude): "))
+		llm = normalizeLLM(cfg, promptOptional(selectAILabel(cfg, "")))
 	}
 	if llm == "" {
-		return fmt.Errorf("invalid AI selection (expected one of: %s)", strings.Join(cfg.LLM.Allowed, ", "))
+		return agentSelectionError(cfg)
 	}
-	useSeparateInitialPrompt := promptYesNoDefault("Use separate initial prompt for AI run? [y/N]: ", false)
-	if useSeparateInitialPrompt {
-		initialPrompt = ""
-	} else {
+	switch {
+	case flags.has("prompt-file"):
+		if initialPrompt, err = readPromptFile(flags.value("prompt-file")); err != nil {
+			return err
+		}
+	case isInteractive() && promptYesNoDefault("Use separate initial prompt for AI run? [y/N]: ", false):
+		if initialPrompt, err = editInitialPrompt(task); err != nil {
+			return err
+		}
+	default:
 		initialPrompt = task
 	}
 
-	return createWorktree(cfg, task, base, llm, initialPrompt, true)
+	_, err = createWorktree(cfg, worktreeOptions{
+		task:          task,
+		base:          base,
+		llm:           llm,
+		initialPrompt: initialPrompt,
+		runTask:       true,
+		push:          cfg.Push == pushOnCreate && !flags.has("no-push"),
+		onCollision:   onCollision,
+		issue:         issue,
+		yes:           flags.has("yes") || flags.has("y"),
+		template:      template,
+		detach:        flags.has("detach"),
+	})
+	return err
 }
 
 func runNewWorktree(cfg config, args []string, runTask bool) error {
+	args, flags, err := parseFlags(args, []string{"no-push", "yes", "y", "edit", "detach"}, append([]string{"from", "existing", "on-collision", "issue", "prompt-file", "template"}, remoteFlags...))
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	onCollision, err := collisionPolicy(cfg, flags)
+	if err != nil {
+		return err
+	}
+	template, err := promptTemplateName(cfg, flags)
+	if err != nil {
+		return err
+	}
+
 	var task string
 	base := cfg.DefaultBaseBranch
 	llm := cfg.LLM.Default
@@ -166,10 +358,41 @@ func runNewWorktree(cfg config, args []string, runTask bool) error {
 		llm = args[2]
 	}
 
-	if strings.TrimSpace(task) == "" {
-		task = promptRequired("Task description: ")
-		base = promptBaseBranch(cfg.DefaultBaseBranch)
-		llm = promptDefault("Select AI (codex/claude) ["+cfg.LLM.Default+"]: ", cfg.LLM.Default)
+	existing := strings.TrimSpace(flags.value("existing"))
+	if existing != "" && flags.has("from") {
+		return errors.New("--from and --existing cannot be used together")
+	}
+	if task == "-" && flags.value("prompt-file") == "-" {
+		return errors.New("stdin cannot be both the task and the --prompt-file")
+	}
+	// With the task or prompt on stdin there is nothing left to answer
+	// prompts with.
+	stdinUsed := task == "-" || flags.value("prompt-file") == "-"
+	if task, err = readTextArg(task); err != nil {
+		return err
+	}
+	if flags.has("edit") {
+		if task, err = editTask(cfg, task); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(task) == "" && existing == "" && stdinUsed {
+		return errors.New("no description provided")
+	}
+	// An existing branch already has a name, so the task is optional and
+	// only used to launch the AI.
+	if strings.TrimSpace(task) == "" && existing == "" {
+		if task, err = promptTask(cfg); err != nil {
+			return err
+		}
+		base = promptBaseBranch(cfg.BaseRemote, cfg.DefaultBaseBranch)
+		llm = promptDefault(selectAILabel(cfg, cfg.LLM.Default), cfg.LLM.Default)
+	}
+	initialPrompt := task
+	if flags.has("prompt-file") {
+		if initialPrompt, err = readPromptFile(flags.value("prompt-file")); err != nil {
+			return err
+		}
 	}
 
 	if strings.TrimSpace(base) == "" {
@@ -177,62 +400,226 @@ func runNewWorktree(cfg config, args []string, runTask bool) error {
 	}
 	llm = normalizeLLM(cfg, llm)
 	if llm == "" {
-		return fmt.Errorf("invalid AI selection (expected one of: %s)", strings.Join(cfg.LLM.Allowed, ", "))
-	}
-	return createWorktree(cfg, task, base, llm, task, runTask)
+		return agentSelectionError(cfg)
+	}
+	_, err = createWorktree(cfg, worktreeOptions{
+		task:          task,
+		base:          base,
+		llm:           llm,
+		initialPrompt: initialPrompt,
+		runTask:       runTask && strings.TrimSpace(initialPrompt) != "",
+		push:          cfg.Push == pushOnCreate && !flags.has("no-push"),
+		from:          strings.TrimSpace(flags.value("from")),
+		existing:      existing,
+		onCollision:   onCollision,
+		issue:         flags.value("issue"),
+		yes:           flags.has("yes") || flags.has("y"),
+		template:      template,
+		detach:        flags.has("detach"),
+	})
+	return err
 }
 
-func createWorktree(cfg config, task, base, llm, initialPrompt string, runTask bool) error {
+func createWorktree(cfg config, opts worktreeOptions) (worktreeEntry, error) {
+	task, base, llm := opts.task, opts.base, opts.llm
+
 	if err := requireCmd("git"); err != nil {
-		return err
+		return worktreeEntry{}, err
+	}
+	if opts.detach && opts.runTask {
+		if _, err := headlessArgs(cfg, llm, ""); err != nil {
+			return worktreeEntry{}, err
+		}
 	}
 	if _, err := runCmdCapture("", "git", "rev-parse", "--is-inside-work-tree"); err != nil {
-		return errors.New("not inside a git repository")
+		return worktreeEntry{}, errors.New("not inside a git repository")
 	}
 
 	repoRootRaw, err := runCmdCapture("", "git", "rev-parse", "--show-toplevel")
 	if err != nil {
-		return err
+		return worktreeEntry{}, err
 	}
 	repoRoot := strings.TrimSpace(repoRootRaw)
 
-	rawBranch := generateBranchName(cfg, task, llm)
-	branch := sanitizeBranch(rawBranch)
-	if branch == "" {
-		return errors.New("empty branch name after sanitize")
-	}
-
 	worktreesDir := filepath.Join(repoRoot, cfg.WorktreesDir)
-	targetPath := filepath.Join(worktreesDir, strings.ReplaceAll(branch, "/", "__"))
 
-	if err := runCmdStream("", "git", "fetch", "origin", base, "--prune"); err != nil {
-		return err
+	var branch string
+	reusePath := ""
+	issue := normalizeIssue(opts.issue)
+	naming := branchSuggestion{Source: branchSourceExisting}
+	if opts.existing != "" {
+		branch = strings.TrimPrefix(opts.existing, "refs/heads/")
+		if runCmd("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch) != nil {
+			return worktreeEntry{}, fmt.Errorf("local branch not found: %s", branch)
+		}
+	} else {
+		if issue == "" {
+			issue = detectIssue(cfg.BranchNaming, task)
+		}
+		if issue == "" && cfg.BranchNaming.RequireIssue {
+			return worktreeEntry{}, errors.New("an issue key is required; pass --issue or mention it in the task (e.g. PROJ-123 or #123)")
+		}
+		if opts.naming != nil {
+			naming = *opts.naming
+		} else {
+			naming, err = chooseBranchName(cfg, task, llm, issue, opts.prefix, opts.yes)
+			if err != nil {
+				return worktreeEntry{}, err
+			}
+		}
+		branch, reusePath, err = resolveBranchCollision(cfg, opts.onCollision, worktreesDir, naming.Branch)
+		if err != nil {
+			return worktreeEntry{}, err
+		}
 	}
-	if err := os.MkdirAll(worktreesDir, 0o755); err != nil {
-		return err
+
+	targetPath := worktreeTargetPath(worktreesDir, branch)
+	if reusePath != "" {
+		targetPath = reusePath
 	}
 
-	remoteExists := runCmd("git", "ls-remote", "--exit-code", "--heads", "origin", branch) == nil
-	if remoteExists {
-		if err := runCmdStream("", "git", "worktree", "add", "--checkout", targetPath, "origin/"+branch); err != nil {
-			return err
+	startPoint := cfg.BaseRemote + "/" + base
+	startLabel := startPoint
+	localExists := runCmd("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch) == nil
+	switch {
+	case localExists:
+		startLabel = "existing branch"
+	case opts.from != "":
+		startPoint, err = resolveStartPoint(cfg, opts.from)
+		if err != nil {
+			return worktreeEntry{}, err
+		}
+		startLabel = opts.from
+	default:
+		if err := runCmdStream("", "git", "fetch", cfg.BaseRemote, base, "--prune"); err != nil {
+			return worktreeEntry{}, err
+		}
+	}
+	if err := os.MkdirAll(worktreesDir, 0o755); err != nil {
+		return worktreeEntry{}, err
+	}
+
+	remoteExists := runCmd("git", "ls-remote", "--exit-code", "--heads", cfg.PushRemote, branch) == nil
+	startCommit := ""
+	hasUpstream := false
+	switch {
+	case reusePath != "":
+		hasUpstream = runCmd("git", "-C", targetPath, "rev-parse", "--abbrev-ref", "@{upstream}") == nil
+	case localExists:
+		if err := runCmdStream("", "git", "worktree", "add", targetPath, branch); err != nil {
+			return worktreeEntry{}, err
+		}
+		// Keep whatever the existing branch already tracks.
+		hasUpstream = runCmd("git", "-C", targetPath, "rev-parse", "--abbrev-ref", "@{upstream}") == nil
+	case remoteExists:
+		if opts.from != "" {
+			fmt.Printf("Branch '%s' already exists on %s; reusing it instead of starting from %s.\n", branch, cfg.PushRemote, opts.from)
+		}
+		if err := runCmdStream("", "git", "fetch", cfg.PushRemote, branch+":refs/remotes/"+cfg.PushRemote+"/"+branch); err != nil {
+			return worktreeEntry{}, err
+		}
+		if err := runCmdStream("", "git", "worktree", "add", "--checkout", targetPath, cfg.PushRemote+"/"+branch); err != nil {
+			return worktreeEntry{}, err
 		}
 		if err := runCmd("git", "-C", targetPath, "switch", "-c", branch); err != nil {
 			if err2 := runCmdStream("", "git", "-C", targetPath, "switch", branch); err2 != nil {
-				return err2
+				return worktreeEntry{}, err2
 			}
 		}
-	} else {
-		if err := runCmdStream("", "git", "worktree", "add", "-b", branch, targetPath, "origin/"+base); err != nil {
-			return err
+	default:
+		if err := runCmdStream("", "git", "worktree", "add", "-b", branch, targetPath, startPoint); err != nil {
+			return worktreeEntry{}, err
+		}
+		if out, err := runCmdCapture(targetPath, "git", "rev-parse", "HEAD"); err == nil {
+			startCommit = strings.TrimSpace(out)
 		}
 	}
 
-	_ = runCmd("git", "-C", targetPath, "branch", "--unset-upstream")
-	if err := runCmdStream("", "git", "-C", targetPath, "push", "-u", "origin", branch+":"+branch); err != nil {
-		return err
+	pushed := false
+	if !hasUpstream {
+		_ = runCmd("git", "-C", targetPath, "branch", "--unset-upstream")
+		if opts.push {
+			if err := runCmdStream("", "git", "-C", targetPath, "push", "-u", cfg.PushRemote, branch+":"+branch); err != nil {
+				return worktreeEntry{}, err
+			}
+			pushed = true
+		} else if remoteExists {
+			_ = runCmd("git", "-C", targetPath, "branch", "--set-upstream-to="+cfg.PushRemote+"/"+branch)
+		}
 	}
 
+	// A reused worktree is already set up and keeps its metadata.
+	if reusePath == "" {
+		meta := worktreeMeta{
+			Branch:      branch,
+			Path:        targetPath,
+			Base:        base,
+			Remote:      cfg.PushRemote,
+			Pushed:      pushed && !remoteExists,
+			Issue:       issue,
+			IssueURL:    opts.issueURL,
+			StartCommit: startCommit,
+			Naming: &branchNamingMeta{
+				Source:      naming.Source,
+				Summary:     naming.Summary,
+				CommitTitle: naming.CommitTitle,
+			},
+			CreatedAt: time.Now(),
+		}
+		if err := saveWorktreeMeta(targetPath, meta); err != nil {
+			fmt.Printf("Warning: failed to save wtx metadata: %v\n", err)
+		}
+
+		if err := bootstrapWorktree(cfg, repoRoot, targetPath, branch); err != nil {
+			return worktreeEntry{}, err
+		}
+	}
+
+	if reusePath != "" {
+		fmt.Printf("Worktree reused at: %s\n", targetPath)
+	} else {
+		fmt.Printf("Worktree created at: %s\n", targetPath)
+	}
+	fmt.Printf("Branch: %s (base: %s)\n", branch, startLabel)
+	upstream, upstreamErr := runCmdCapture(targetPath, "git", "rev-parse", "--abbrev-ref", "@{upstream}")
+	switch {
+	case upstreamErr == nil:
+		fmt.Printf("Upstream: %s\n", strings.TrimSpace(upstream))
+	case cfg.Push == pushNever:
+		fmt.Println("Upstream: none (local only)")
+	default:
+		fmt.Println("Upstream: none yet (wtx propen pushes the branch)")
+	}
+
+	if opts.runTask {
+		prompt := opts.initialPrompt
+		if opts.template != "" {
+			prompt = renderPromptTemplate(cfg, opts.template, promptContext{
+				task:      prompt,
+				branch:    branch,
+				base:      base,
+				issue:     issue,
+				issueBody: opts.issueBody,
+				worktree:  targetPath,
+			})
+		}
+		if opts.detach {
+			if err := detachLLMTask(cfg, llm, branch, targetPath, prompt); err != nil {
+				return worktreeEntry{}, err
+			}
+		} else {
+			fmt.Printf("Running %s with task prompt...\n", agentName(cfg, llm))
+			if err := runLLMTask(cfg, llm, targetPath, prompt); err != nil {
+				return worktreeEntry{}, err
+			}
+		}
+	}
+	return worktreeEntry{path: targetPath, branch: branch}, nil
+}
+
+// bootstrapWorktree prepares a freshly added worktree: it copies the
+// configured files from repoRoot and runs the post-create hooks.
+func bootstrapWorktree(cfg config, repoRoot, targetPath, branch string) error {
 	fmt.Println("Copying configured files...")
 	for _, item := range cfg.CopyFiles {
 		from := strings.TrimSpace(item.From)
@@ -260,7 +647,13 @@ func createWorktree(cfg config, task, base, llm, initialPrompt string, runTask b
 	}
 
 	fmt.Println("Running post-create hooks...")
-	for _, hook := range cfg.PostCreateHooks {
+	return runHooks(cfg.PostCreateHooks, targetPath, hookEnv(targetPath, branch))
+}
+
+// runHooks runs hooks in order. A hook's cwd is relative to baseDir, and env
+// is added to the environment of every hook command.
+func runHooks(hooks []hookConfig, baseDir string, env []string) error {
+	for _, hook := range hooks {
 		if len(hook.Command) == 0 {
 			continue
 		}
@@ -269,9 +662,9 @@ func createWorktree(cfg config, task, base, llm, initialPrompt string, runTask b
 			name = strings.Join(hook.Command, " ")
 		}
 
-		hookDir := targetPath
+		hookDir := baseDir
 		if strings.TrimSpace(hook.Cwd) != "" {
-			hookDir = filepath.Join(targetPath, hook.Cwd)
+			hookDir = filepath.Join(baseDir, hook.Cwd)
 		}
 		if !isDir(hookDir) {
 			if hook.SkipIfMissing {
@@ -282,25 +675,36 @@ func createWorktree(cfg config, task, base, llm, initialPrompt string, runTask b
 		}
 
 		fmt.Printf("Hook: %s\n", name)
-		if err := runCmdStream(hookDir, hook.Command[0], hook.Command[1:]...); err != nil {
+		if err := runCmdStreamEnv(hookDir, env, hook.Command[0], hook.Command[1:]...); err != nil {
 			return err
 		}
 	}
+	return nil
+}
 
-	fmt.Printf("Worktree created at: %s\n", targetPath)
-	fmt.Printf("Branch: %s (base: origin/%s)\n", branch, base)
-	fmt.Printf("Upstream: origin/%s\n", branch)
+func hookEnv(worktreePath, branch string) []string {
+	return []string{
+		"WTX_WORKTREE_PATH=" + worktreePath,
+		"WTX_BRANCH=" + branch,
+	}
+}
 
-	if runTask {
-		fmt.Printf("Running %s with task prompt...\n", llm)
-		if err := runLLMTask(cfg, llm, targetPath, initialPrompt); err != nil {
+func runClean(cfg config, args []string) error {
+	_, flags, err := parseFlags(args, []string{"archive", "dry-run"}, append([]string{"older-than"}, remoteFlags...))
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	archive := cfg.Archive.Enabled || flags.has("archive")
+	dryRun := flags.has("dry-run")
+	var olderThan time.Duration
+	if flags.has("older-than") {
+		olderThan, err = parseAge(flags.value("older-than"))
+		if err != nil {
 			return err
 		}
 	}
-	return nil
-}
 
-func runClean(cfg config) error {
 	if err := requireCmd("git"); err != nil {
 		return err
 	}
@@ -315,26 +719,52 @@ func runClean(cfg config) error {
 	}
 
 	mainWorktree := entries[0].path
+	if dryRun {
+		fmt.Println("Dry run: nothing will be removed.")
+	}
 
 	// Remove worktrees whose directories no longer exist on disk.
 	fmt.Println("Checking for stale worktrees (missing directories)...")
 	for _, e := range entries {
 		branch := strings.TrimPrefix(e.branch, "refs/heads/")
-		if branch == "" || branch == cfg.MainBranch {
+		if branch == "" || isProtectedBranch(cfg, branch) || e.locked {
 			continue
 		}
 		if isDir(e.path) {
 			continue
 		}
+		if dryRun {
+			fmt.Printf("Directory missing for branch '%s' (%s). Would %sremove worktree and branch.\n", branch, e.path, archiveNote(archive))
+			continue
+		}
 		fmt.Printf("Directory missing for branch '%s' (%s). Removing worktree...\n", branch, e.path)
+		// The directory is gone, but the branch's commits can still be
+		// bundled.
+		if archive {
+			dir, err := archiveWorktree(cfg, mainWorktree, e.path, branch)
+			if err != nil {
+				return fmt.Errorf("failed to archive '%s': %w", branch, err)
+			}
+			fmt.Printf("Archived to: %s\n", dir)
+		}
 		_ = runCmd("git", "worktree", "remove", e.path, "--force")
 		if err := runCmd("git", "branch", "-d", branch); err != nil {
 			_ = runCmd("git", "branch", "-D", branch)
 		}
+		_ = removeWorktreeMeta(mainWorktree, branch)
 		fmt.Printf("Removed stale worktree and branch: %s\n", branch)
 	}
-	// Prune any remaining stale worktree metadata.
-	_ = runCmd("git", "worktree", "prune")
+	if !dryRun {
+		// Prune any remaining stale worktree metadata.
+		_ = runCmd("git", "worktree", "prune")
+	}
+	// Refresh remote-tracking refs so upstreams deleted after merge show as
+	// gone. A dry run leaves the refs alone and works with what is known.
+	if !dryRun {
+		for _, remote := range configuredRemotes(cfg) {
+			_ = runCmdIn(mainWorktree, "git", "fetch", remote, "--prune")
+		}
+	}
 
 	// Re-read worktree list after pruning stale entries.
 	listRaw, err = runCmdCapture("", "git", "worktree", "list", "--porcelain")
@@ -343,25 +773,50 @@ func runClean(cfg config) error {
 	}
 	entries = parseWorktreeList(listRaw)
 
-	// Remove worktrees whose branches have been merged.
+	// Remove worktrees whose branches have been merged, lost their upstream,
+	// or were abandoned longer than --older-than.
 	fmt.Println("Checking for merged worktrees...")
 	for _, e := range entries {
 		branch := strings.TrimPrefix(e.branch, "refs/heads/")
-		if branch == "" || branch == cfg.MainBranch {
+		if branch == "" || isProtectedBranch(cfg, branch) {
+			continue
+		}
+		if e.locked {
+			fmt.Printf("Worktree for '%s' is locked%s. Keeping worktree.\n", branch, lockNote(e))
 			continue
 		}
 
-		merged := runCmdIn(mainWorktree, "git", "merge-base", "--is-ancestor", branch, cfg.MainBranch) == nil
-		if !merged {
-			// Squash merges don't preserve ancestry; check GitHub PR state as fallback.
-			merged = isBranchSquashMerged(branch)
+		d := cleanReason(cfg, mainWorktree, e.path, branch, olderThan, archive)
+		if !d.remove {
+			fmt.Printf("Branch '%s' %s. Keeping worktree.\n", branch, d.reason)
+			continue
 		}
-		if !merged {
-			fmt.Printf("Branch '%s' is not merged yet. Keeping worktree.\n", branch)
+
+		// Only branches confirmed merged that wtx itself pushed are deleted on
+		// the remote; anything else may still be someone's work in progress.
+		meta, _ := loadWorktreeMeta(mainWorktree, branch)
+		deleteRemote := cfg.DeleteRemoteBranches && d.merged && meta.Pushed && meta.Remote != "" &&
+			runCmdIn(mainWorktree, "git", "ls-remote", "--exit-code", "--heads", meta.Remote, branch) == nil
+
+		if dryRun {
+			fmt.Printf("Branch '%s' %s. Would remove worktree at '%s' and branch.\n", branch, d.reason, e.path)
+			if deleteRemote {
+				fmt.Printf("Would delete remote branch: %s/%s\n", meta.Remote, branch)
+			}
 			continue
 		}
 
-		fmt.Printf("Branch '%s' is merged. Removing worktree at '%s'...\n", branch, e.path)
+		fmt.Printf("Branch '%s' %s. Removing worktree at '%s'...\n", branch, d.reason, e.path)
+		if err := runHooks(cfg.PreRemoveHooks, e.path, hookEnv(e.path, branch)); err != nil {
+			return err
+		}
+		if archive {
+			dir, err := archiveWorktree(cfg, mainWorktree, e.path, branch)
+			if err != nil {
+				return fmt.Errorf("failed to archive '%s': %w", branch, err)
+			}
+			fmt.Printf("Archived to: %s\n", dir)
+		}
 		if err := runCmdStream("", "git", "worktree", "remove", e.path, "--force"); err != nil {
 			return err
 		}
@@ -370,19 +825,124 @@ func runClean(cfg config) error {
 				return err2
 			}
 		}
+		if deleteRemote {
+			fmt.Printf("Deleting remote branch: %s/%s\n", meta.Remote, branch)
+			if err := runCmdStream(mainWorktree, "git", "push", meta.Remote, "--delete", branch); err != nil {
+				fmt.Printf("Failed to delete remote branch %s/%s: %v\n", meta.Remote, branch, err)
+			}
+		}
+		if err := runHooks(cfg.PostRemoveHooks, mainWorktree, hookEnv(e.path, branch)); err != nil {
+			return err
+		}
+		_ = removeWorktreeMeta(mainWorktree, branch)
 		fmt.Printf("Removed worktree and branch: %s\n", branch)
 	}
 	fmt.Println("Done cleaning worktrees.")
 	return nil
 }
 
+func archiveNote(archive bool) string {
+	if archive {
+		return "archive and "
+	}
+	return ""
+}
+
+// cleanDecision is the outcome of cleanReason for one worktree. reason
+// describes why it is removed or kept.
+type cleanDecision struct {
+	remove bool
+	merged bool
+	reason string
+}
+
+// cleanReason decides whether the worktree at path should be cleaned. Work
+// that exists nowhere else (uncommitted changes, or unpushed commits that no
+// archive keeps) is never removed.
+func cleanReason(cfg config, mainWorktree, path, branch string, olderThan time.Duration, archive bool) cleanDecision {
+	// Squash merges don't preserve ancestry, so later strategies compare
+	// patches and trees locally before falling back to the forge.
+	if merged, strategy := isBranchMerged(cfg, mainWorktree, branch); merged {
+		return cleanDecision{remove: true, merged: true, reason: "is merged (" + strategy + ")"}
+	}
+
+	unpushed := hasUnpushedCommits(mainWorktree, branch)
+	if isUpstreamGone(mainWorktree, branch) {
+		if unpushed {
+			return cleanDecision{reason: "has a deleted upstream but unpushed commits"}
+		}
+		if isWorktreeDirty(path) {
+			return cleanDecision{reason: "has a deleted upstream but uncommitted changes"}
+		}
+		return cleanDecision{remove: true, reason: "has a deleted upstream and no unpushed commits"}
+	}
+
+	if olderThan > 0 {
+		last, err := lastCommitTime(mainWorktree, branch)
+		if err == nil && time.Since(last) > olderThan {
+			if isWorktreeDirty(path) {
+				return cleanDecision{reason: "is inactive but has uncommitted changes"}
+			}
+			// The archive's bundle keeps unpushed commits; without one they
+			// would be lost with the branch.
+			if unpushed && !archive {
+				return cleanDecision{reason: "is inactive but has unpushed commits (pass --archive to remove it anyway)"}
+			}
+			days := int(time.Since(last).Hours() / 24)
+			return cleanDecision{remove: true, reason: fmt.Sprintf("has had no commits for %d days", days)}
+		}
+	}
+	return cleanDecision{reason: "is not merged yet"}
+}
+
+// isUpstreamGone reports whether branch tracks an upstream that was deleted on
+// the remote and pruned locally.
+func isUpstreamGone(dir, branch string) bool {
+	out, err := runCmdCapture(dir, "git", "for-each-ref", "--format=%(upstream:track)", "refs/heads/"+branch)
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(out) == "[gone]"
+}
+
+// hasUnpushedCommits reports whether branch has commits not reachable from any
+// remote-tracking ref.
+func hasUnpushedCommits(dir, branch string) bool {
+	out, err := runCmdCapture(dir, "git", "rev-list", "--count", branch, "--not", "--remotes")
+	if err != nil {
+		return true
+	}
+	return strings.TrimSpace(out) != "0"
+}
+
+func lastCommitTime(dir, branch string) (time.Time, error) {
+	out, err := runCmdCapture(dir, "git", "log", "-1", "--format=%ct", branch)
+	if err != nil {
+		return time.Time{}, err
+	}
+	sec, err := strconv.ParseInt(strings.TrimSpace(out), 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(sec, 0), nil
+}
+
+func isWorktreeDirty(path string) bool {
+	out, err := runCmdCapture("", "git", "-C", path, "status", "--porcelain")
+	if err != nil {
+		return true
+	}
+	return strings.TrimSpace(out) != ""
+}
+
 // isBranchSquashMerged checks if a branch has a merged PR on GitHub.
 // This catches squash-merged branches that git merge-base --is-ancestor misses.
-func isBranchSquashMerged(branch string) bool {
+func isBranchSquashMerged(cfg config, branch string) bool {
 	if !commandExists("gh") {
 		return false
 	}
-	out, err := runCmdCapture("", "gh", "pr", "list", "--head", branch, "--state", "merged", "--json", "number", "--limit", "1")
+	args := append([]string{"pr", "list", "--head", branch, "--state", "merged", "--json", "number", "--limit", "1"}, ghRepoArgs(cfg)...)
+	out, err := runCmdCapture("", "gh", args...)
 	if err != nil {
 		return false
 	}
@@ -412,14 +972,18 @@ func runSwitch(args []string) error {
 		return err
 	}
 
+	return openShell(selected.path)
+}
+
+func openShell(dir string) error {
 	shell := strings.TrimSpace(os.Getenv("SHELL"))
 	if shell == "" {
 		shell = "/bin/sh"
 	}
-	fmt.Printf("Launching shell in: %s\n", selected.path)
+	fmt.Printf("Launching shell in: %s\n", dir)
 
 	cmd := exec.Command(shell)
-	cmd.Dir = selected.path
+	cmd.Dir = dir
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -481,7 +1045,73 @@ func runCode(args []string) error {
 	return runCmdStream("", "code", selected.path)
 }
 
-func runPROpen(args []string) error {
+func runLock(args []string) error {
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return errors.New("no worktrees found")
+	}
+
+	selected, err := selectWorktree(entries, args[:min(len(args), 1)])
+	if err != nil {
+		return err
+	}
+	if selected.locked {
+		fmt.Printf("Worktree already locked: %s\n", selected.path)
+		return nil
+	}
+
+	lockArgs := []string{"worktree", "lock"}
+	if len(args) > 1 {
+		lockArgs = append(lockArgs, "--reason", strings.Join(args[1:], " "))
+	}
+	if err := runCmdStream("", "git", append(lockArgs, selected.path)...); err != nil {
+		return err
+	}
+	fmt.Printf("Locked worktree: %s\n", selected.path)
+	return nil
+}
+
+func runUnlock(args []string) error {
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return errors.New("no worktrees found")
+	}
+
+	selected, err := selectWorktree(entries, args)
+	if err != nil {
+		return err
+	}
+	if !selected.locked {
+		fmt.Printf("Worktree is not locked: %s\n", selected.path)
+		return nil
+	}
+	if err := runCmdStream("", "git", "worktree", "unlock", selected.path); err != nil {
+		return err
+	}
+	fmt.Printf("Unlocked worktree: %s\n", selected.path)
+	return nil
+}
+
+func runPROpen(cfg config, args []string) error {
+	args, flags, err := parseFlags(args, nil, remoteFlags)
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
 	if err := requireCmd("git"); err != nil {
 		return err
 	}
@@ -501,9 +1131,17 @@ func runPROpen(args []string) error {
 		return errors.New("detached HEAD is not supported; switch to a branch first")
 	}
 
-	if runCmd("gh", "pr", "view", branch) == nil {
+	if err := pushForPR(cfg, branch); err != nil {
+		return err
+	}
+
+	// In fork workflows the PR lives in the base remote's repository and its
+	// head is the branch in the push remote's fork.
+	repoArgs := ghRepoArgs(cfg)
+	head := prHead(cfg, branch)
+	if runCmd("gh", append([]string{"pr", "view", head}, repoArgs...)...) == nil {
 		fmt.Printf("Opening existing PR for branch '%s'...\n", branch)
-		return runCmdStream("", "gh", "pr", "view", branch, "--web")
+		return runCmdStream("", "gh", append([]string{"pr", "view", head, "--web"}, repoArgs...)...)
 	}
 
 	base := ""
@@ -511,17 +1149,110 @@ func runPROpen(args []string) error {
 		base = strings.TrimSpace(args[0])
 	}
 	if base == "" {
-		base = detectDefaultBaseBranch()
+		base = detectDefaultBaseBranch(cfg)
 	}
 	if base == "" {
 		return errors.New("could not determine base branch; pass it explicitly: wtx propen <base-branch>")
 	}
 
-	fmt.Printf("No existing PR found. Creating PR for '%s' -> '%s'...\n", branch, base)
-	return runCmdStream("", "gh", "pr", "create", "--head", branch, "--base", base, "--fill", "--web")
+	createArgs := []string{"pr", "create", "--head", head, "--base", base}
+	if closes := closingIssueRef(branch); closes != "" {
+		// --fill cannot be combined with a body, so fill it in the same way
+		// and append the closing reference.
+		title, body := prTitleBody(cfg, base, branch)
+		if body != "" {
+			body += "\n\n"
+		}
+		createArgs = append(createArgs, "--title", title, "--body", body+closes)
+	} else {
+		createArgs = append(createArgs, "--fill")
+	}
+
+	fmt.Printf("No existing PR found. Creating PR for '%s' -> '%s'...\n", head, base)
+	return runCmdStream("", "gh", append(append(createArgs, "--web"), repoArgs...)...)
+}
+
+// prTitleBody mirrors gh's --fill: a single commit gives the title and body,
+// several commits give the branch name as title and their subjects as body.
+func prTitleBody(cfg config, base, branch string) (string, string) {
+	out, err := runCmdCapture("", "git", "log", "--reverse", "--format=%s", cfg.BaseRemote+"/"+base+"..HEAD")
+	subjects := strings.Split(strings.TrimSpace(out), "\n")
+	if err != nil || len(subjects) == 0 || subjects[0] == "" {
+		return branch, ""
+	}
+	if len(subjects) == 1 {
+		body, _ := runCmdCapture("", "git", "log", "-1", "--format=%b")
+		return subjects[0], strings.TrimSpace(body)
+	}
+	lines := make([]string, 0, len(subjects))
+	for _, sub := range subjects {
+		lines = append(lines, "- "+sub)
+	}
+	return branch, strings.Join(lines, "\n")
+}
+
+// resolveStartPoint turns a --from value into a commit to branch from. Local
+// branches, tags and commits resolve directly; "pr:<number>" and refs that only
+// exist on the base remote are fetched first.
+func resolveStartPoint(cfg config, ref string) (string, error) {
+	if n, ok := strings.CutPrefix(ref, "pr:"); ok {
+		n = strings.TrimPrefix(n, "#")
+		if _, err := strconv.Atoi(n); err != nil {
+			return "", fmt.Errorf("invalid pull request reference: %s (expected pr:<number>)", ref)
+		}
+		fmt.Printf("Fetching pull request #%s from %s...\n", n, cfg.BaseRemote)
+		if err := runCmdStream("", "git", "fetch", cfg.BaseRemote, "refs/pull/"+n+"/head"); err != nil {
+			return "", err
+		}
+		return revParseCommit("FETCH_HEAD")
+	}
+
+	if sha, err := revParseCommit(ref); err == nil {
+		return sha, nil
+	}
+	if runCmd("git", "fetch", cfg.BaseRemote, ref) == nil {
+		return revParseCommit("FETCH_HEAD")
+	}
+	return "", fmt.Errorf("cannot resolve start point: %s", ref)
+}
+
+func revParseCommit(ref string) (string, error) {
+	out, err := runCmdCapture("", "git", "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s", ref)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// pushForPR makes sure branch exists on the push remote before a PR is
+// opened. With push set to onPR the branch is always pushed so the PR shows
+// the latest commits; otherwise it is only pushed when the remote lacks it.
+func pushForPR(cfg config, branch string) error {
+	onRemote := runCmd("git", "ls-remote", "--exit-code", "--heads", cfg.PushRemote, branch) == nil
+	if onRemote && cfg.Push != pushOnPR {
+		return nil
+	}
+	if cfg.Push == pushNever {
+		return fmt.Errorf("branch '%s' is not on %s and push is set to never; push it first", branch, cfg.PushRemote)
+	}
+
+	fmt.Printf("Pushing '%s' to %s...\n", branch, cfg.PushRemote)
+	if err := runCmdStream("", "git", "push", "-u", cfg.PushRemote, branch+":"+branch); err != nil {
+		return err
+	}
+	if !onRemote {
+		if meta, ok := loadWorktreeMeta("", branch); ok {
+			meta.Remote = cfg.PushRemote
+			meta.Pushed = true
+			if err := saveWorktreeMeta("", meta); err != nil {
+				fmt.Printf("Warning: failed to save wtx metadata: %v\n", err)
+			}
+		}
+	}
+	return nil
 }
 
-func runRemoteCheckout(args []string) error {
+func runRemoteCheckout(cfg config, args []string) error {
 	if err := requireCmd("git"); err != nil {
 		return err
 	}
@@ -529,15 +1260,28 @@ func runRemoteCheckout(args []string) error {
 		return errors.New("not inside a git repository")
 	}
 
-	const remote = "origin"
-	if err := runCmdStream("", "git", "fetch", remote, "--prune"); err != nil {
+	args, flags, err := parseFlags(args, []string{"worktree", "no-worktree", "open"}, remoteFlags)
+	if err != nil {
 		return err
 	}
+	applyRemoteFlags(&cfg, flags)
+	inWorktree := (cfg.CoWorktree || flags.has("worktree")) && !flags.has("no-worktree")
 
+	// Branches default to the base remote; a "<remote>/" prefix naming the
+	// push remote selects it instead. Other prefixes are part of the branch
+	// name, e.g. feature/x.
+	remote := cfg.BaseRemote
 	branch := ""
 	if len(args) > 0 {
 		branch = strings.TrimSpace(args[0])
 	}
+	if r, rest, ok := strings.Cut(branch, "/"); ok && slices.Contains(configuredRemotes(cfg), r) {
+		remote, branch = r, rest
+	}
+	if err := runCmdStream("", "git", "fetch", remote, "--prune"); err != nil {
+		return err
+	}
+
 	if branch == "" {
 		branches, err := recentRemoteBranches(remote, 20)
 		if err != nil || len(branches) == 0 {
@@ -549,7 +1293,7 @@ func runRemoteCheckout(args []string) error {
 			fmt.Printf("  %d) %s\n", i+1, b)
 		}
 		fmt.Print("Enter number or branch name: ")
-		in, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		in, _ := stdinReader.ReadString('\n')
 		in = strings.TrimSpace(in)
 		if in == "" {
 			return errors.New("no branch provided")
@@ -577,6 +1321,16 @@ func runRemoteCheckout(args []string) error {
 	if runCmd("git", "show-ref", "--verify", "--quiet", remoteRef) != nil {
 		return fmt.Errorf("remote branch not found: %s/%s", remote, branch)
 	}
+	if inWorktree {
+		path, err := checkoutRemoteBranchWorktree(cfg, remote, branch)
+		if err != nil {
+			return err
+		}
+		if flags.has("open") {
+			return openShell(path)
+		}
+		return nil
+	}
 
 	localRef := "refs/heads/" + branch
 	localExists := runCmd("git", "show-ref", "--verify", "--quiet", localRef) == nil
@@ -609,6 +1363,76 @@ func runRemoteCheckout(args []string) error {
 	return nil
 }
 
+// checkoutRemoteBranchWorktree gives remote/branch its own worktree under
+// worktreesDir instead of switching the current one. A worktree that already
+// has the branch checked out is reused and fast-forwarded when possible.
+func checkoutRemoteBranchWorktree(cfg config, remote, branch string) (string, error) {
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return "", errors.New("no worktrees found")
+	}
+	mainWorktree := entries[0].path
+
+	for _, e := range entries {
+		if strings.TrimPrefix(e.branch, "refs/heads/") != branch {
+			continue
+		}
+		fmt.Printf("Branch '%s' is already checked out at: %s\n", branch, e.path)
+		if err := runCmdStream(e.path, "git", "merge", "--ff-only", remote+"/"+branch); err != nil {
+			fmt.Printf("Could not fast-forward '%s'; resolve divergence manually.\n", branch)
+		}
+		return e.path, nil
+	}
+
+	worktreesDir := filepath.Join(mainWorktree, cfg.WorktreesDir)
+	targetPath := filepath.Join(worktreesDir, strings.ReplaceAll(branch, "/", "__"))
+	if _, err := os.Stat(targetPath); err == nil {
+		return "", fmt.Errorf("target path already exists: %s", targetPath)
+	}
+	if err := os.MkdirAll(worktreesDir, 0o755); err != nil {
+		return "", err
+	}
+
+	localExists := runCmd("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch) == nil
+	if localExists {
+		fmt.Printf("Creating worktree for existing branch '%s'...\n", branch)
+		if err := runCmdStream("", "git", "worktree", "add", targetPath, branch); err != nil {
+			return "", err
+		}
+		_ = runCmd("git", "-C", targetPath, "branch", "--set-upstream-to="+remote+"/"+branch)
+		fmt.Printf("Fast-forwarding '%s' from '%s/%s'...\n", branch, remote, branch)
+		if err := runCmdStream(targetPath, "git", "merge", "--ff-only", remote+"/"+branch); err != nil {
+			fmt.Printf("Could not fast-forward '%s'; resolve divergence manually.\n", branch)
+		}
+	} else {
+		fmt.Printf("Creating worktree with local tracking branch '%s' from '%s/%s'...\n", branch, remote, branch)
+		if err := runCmdStream("", "git", "worktree", "add", "--track", "-b", branch, targetPath, remote+"/"+branch); err != nil {
+			return "", err
+		}
+	}
+
+	meta := worktreeMeta{
+		Branch:    branch,
+		Path:      targetPath,
+		Remote:    remote,
+		CreatedAt: time.Now(),
+	}
+	if err := saveWorktreeMeta(targetPath, meta); err != nil {
+		fmt.Printf("Warning: failed to save wtx metadata: %v\n", err)
+	}
+
+	if err := bootstrapWorktree(cfg, mainWorktree, targetPath, branch); err != nil {
+		return "", err
+	}
+	fmt.Printf("Worktree ready at: %s\n", targetPath)
+	fmt.Printf("Branch: %s tracks %s/%s\n", branch, remote, branch)
+	return targetPath, nil
+}
+
 func switchBranchAllowOtherWorktrees(branch string) error {
 	out, err := runCmdCapture("", "git", "switch", branch)
 	if err == nil {
@@ -644,14 +1468,10 @@ func selectWorktree(entries []worktreeEntry, args []string) (worktreeEntry, erro
 
 	fmt.Println("Select a worktree:")
 	for i, e := range entries {
-		branch := strings.TrimPrefix(e.branch, "refs/heads/")
-		if branch == "" {
-			branch = "(detached)"
-		}
-		fmt.Printf("  %d) %s\n", i+1, branch)
+		fmt.Printf("  %d) %s\n", i+1, worktreeLabel(e))
 	}
 	fmt.Print("Enter number or branch name: ")
-	in, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	in, _ := stdinReader.ReadString('\n')
 	in = strings.TrimSpace(in)
 	if in == "" {
 		return worktreeEntry{}, errors.New("no selection provided")
@@ -659,30 +1479,77 @@ func selectWorktree(entries []worktreeEntry, args []string) (worktreeEntry, erro
 	return selectWorktree(entries, []string{in})
 }
 
-func generateBranchName(cfg config, task, llm string) string {
-	prompt := strings.ReplaceAll(cfg.LLM.BranchNamePromptTemplate, "{task}", task)
-	aiCfg, ok := cfg.LLM.Commands[llm]
-	if ok && commandExists(llm) && len(aiCfg.BranchNameArgsTemplate) > 0 {
-		args := replaceTemplates(aiCfg.BranchNameArgsTemplate, map[string]string{
-			"{prompt}": prompt,
-			"{task}":   task,
-		})
-		out, err := runCmdCapture("", llm, args...)
-		if err == nil {
-			v := extractBranchCandidate(out)
-			if v != "" {
-				return v
+// generateBranchNames asks the AI for branch names (falling back to a slug of
+// the task's first line) and lays them out with the naming template, issue
+// key and, when given, a forced prefix. The best candidate comes first.
+func generateBranchNames(cfg config, task, llm, issue, prefix string, useCache bool) []branchSuggestion {
+	var names []branchSuggestion
+	for _, s := range suggestBranchNames(cfg, task, llm, useCache) {
+		v := s.Branch
+		// The AI's type becomes the prefix only when the policy allows it; an
+		// unknown one is dropped, also from the front of the branch, so that
+		// sanitizeBranch adds the default prefix just once.
+		typ := strings.ToLower(strings.Trim(strings.TrimSpace(s.Type), "/"))
+		if typ != "" && !hasBranchPrefix(cfg.BranchNaming, strings.ToLower(v)) {
+			if strings.HasPrefix(strings.ToLower(v), typ+"/") {
+				v = v[len(typ)+1:]
+			}
+			if hasBranchPrefix(cfg.BranchNaming, typ+"/") {
+				v = typ + "/" + v
 			}
 		}
+		name := sanitizeBranch(cfg.BranchNaming, v)
+		if name == "" {
+			continue
+		}
+		s.Branch = applyBranchTemplate(cfg.BranchNaming, name, issue, prefix)
+		if !slices.ContainsFunc(names, func(x branchSuggestion) bool { return x.Branch == s.Branch }) {
+			names = append(names, s)
+		}
+	}
+	return names
+}
+
+// branchNameCandidates returns the generated names that pass the naming
+// policy, or the first reason none did.
+func branchNameCandidates(cfg config, task, llm, issue, prefix string, useCache bool) ([]branchSuggestion, error) {
+	var names []branchSuggestion
+	var firstErr error
+	for _, s := range generateBranchNames(cfg, task, llm, issue, prefix, useCache) {
+		name, err := finalizeBranch(cfg.BranchNaming, s.Branch)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		s.Branch = name
+		names = append(names, s)
+	}
+	if len(names) == 0 {
+		if firstErr != nil {
+			return nil, firstErr
+		}
+		return nil, errors.New("empty branch name after sanitize")
 	}
+	return names, nil
+}
 
-	fallback := strings.ToLower(task)
-	fallback = regexpReplace(fallback, `[^a-z0-9]+`, "-")
-	fallback = strings.Trim(fallback, "-")
-	if fallback == "" {
-		fallback = "task"
+// chooseBranchName generates branch names for task and, unless yes is set or
+// stdin is not a terminal, lets the user confirm, edit or regenerate them.
+func chooseBranchName(cfg config, task, llm, issue, prefix string, yes bool) (branchSuggestion, error) {
+	candidates, err := branchNameCandidates(cfg, task, llm, issue, prefix, true)
+	if err != nil {
+		return branchSuggestion{}, err
+	}
+	if yes || !isInteractive() {
+		return candidates[0], nil
 	}
-	return fallback
+	// Regenerating must ask the AI again instead of the cache.
+	regenerate := func() ([]branchSuggestion, error) {
+		return branchNameCandidates(cfg, task, llm, issue, prefix, false)
+	}
+	return confirmBranchName(cfg.BranchNaming, candidates, regenerate)
 }
 
 func runLLMTask(cfg config, llm, worktreePath, task string) error {
@@ -690,12 +1557,16 @@ func runLLMTask(cfg config, llm, worktreePath, task string) error {
 	if !ok {
 		return fmt.Errorf("missing LLM command config for: %s", llm)
 	}
-	if !commandExists(llm) {
-		fmt.Printf("%s not found. Skip auto-run.\n", llm)
+	if !commandExists(agentBinary(cfg, llm)) {
+		fmt.Printf("%s not found. Skip auto-run.\n", agentBinary(cfg, llm))
 		return nil
 	}
 	if task == "" {
-		return runCmdStream(worktreePath, llm)
+		if !isInteractiveAgent(cfg, llm) {
+			fmt.Printf("%s is not interactive and there is no task. Skip auto-run.\n", agentName(cfg, llm))
+			return nil
+		}
+		return runAgent(cfg, llm, worktreePath)
 	}
 	args := replaceTemplates(aiCfg.TaskRunArgsTemplate, map[string]string{
 		"{task}": task,
@@ -703,54 +1574,7 @@ func runLLMTask(cfg config, llm, worktreePath, task string) error {
 	if len(args) == 0 {
 		return fmt.Errorf("empty taskRunArgsTemplate for %s", llm)
 	}
-	return runCmdStream(worktreePath, llm, args...)
-}
-
-func sanitizeBranch(v string) string {
-	s := strings.ToLower(strings.TrimSpace(v))
-	s = regexpReplace(s, `[^a-z0-9/-]+`, "-")
-	s = regexpReplace(s, `-+`, "-")
-	s = regexpReplace(s, `/+`, "/")
-	s = strings.Trim(s, "-")
-	s = strings.Trim(s, "/")
-	if s == "" {
-		return ""
-	}
-
-	prefixOK := strings.HasPrefix(s, "feature/") ||
-		strings.HasPrefix(s, "bugfix/") ||
-		strings.HasPrefix(s, "fix/") ||
-		strings.HasPrefix(s, "chore/") ||
-		strings.HasPrefix(s, "refactor/")
-	if !prefixOK {
-		s = "feature/" + s
-	}
-	if len(s) > 120 {
-		s = strings.TrimRight(s[:120], "-/")
-	}
-	return s
-}
-
-func extractBranchCandidate(out string) string {
-	text := strings.ReplaceAll(out, "\r", "\n")
-	re := regexpMustCompile(`(?m)\b(feature|bugfix|fix|chore|refactor|docs|test)\/[a-z0-9][a-z0-9\-/]{1,120}\b`)
-	matches := re.FindAllString(strings.ToLower(text), -1)
-	for i := len(matches) - 1; i >= 0; i-- {
-		v := sanitizeBranch(matches[i])
-		if v != "" && len(v) <= 120 {
-			return v
-		}
-	}
-
-	parts := strings.Fields(strings.ToLower(text))
-	for i := len(parts) - 1; i >= 0; i-- {
-		token := strings.Trim(parts[i], "\"'`.,:;[](){}<>")
-		v := sanitizeBranch(token)
-		if v != "" && len(v) <= 120 {
-			return v
-		}
-	}
-	return ""
+	return runAgent(cfg, llm, worktreePath, args...)
 }
 
 func loadConfig(path string) (config, error) {
@@ -768,8 +1592,61 @@ func loadConfig(path string) (config, error) {
 	if cfg.MainBranch == "" {
 		cfg.MainBranch = cfg.DefaultBaseBranch
 	}
-	if cfg.LLM.Default == "" {
-		cfg.LLM.Default = "codex"
+	if err := normalizeAgents(&cfg.LLM); err != nil {
+		return cfg, err
+	}
+	switch cfg.LLM.BranchNameFormat {
+	case "":
+		cfg.LLM.BranchNameFormat = branchNameFormatText
+	case branchNameFormatJSON, branchNameFormatText:
+	default:
+		return cfg, fmt.Errorf("invalid llm.branchNameFormat: %q (expected %s or %s)", cfg.LLM.BranchNameFormat, branchNameFormatJSON, branchNameFormatText)
+	}
+	if strings.TrimSpace(cfg.LLM.BranchNameJSONPromptTemplate) == "" {
+		cfg.LLM.BranchNameJSONPromptTemplate = defaultBranchNameJSONPromptTemplate
+	}
+	if cfg.LLM.BranchNameTimeout == "" {
+		cfg.LLM.BranchNameTimeout = "30s"
+	}
+	if d, err := time.ParseDuration(cfg.LLM.BranchNameTimeout); err != nil || d < 0 {
+		return cfg, fmt.Errorf("invalid llm.branchNameTimeout: %q (expected a duration such as 30s or 2m)", cfg.LLM.BranchNameTimeout)
+	}
+	if err := validateProviders(cfg.LLM); err != nil {
+		return cfg, err
+	}
+	if err := validatePromptTemplates(cfg.LLM); err != nil {
+		return cfg, err
+	}
+	if cfg.BaseRemote == "" {
+		cfg.BaseRemote = "origin"
+	}
+	if cfg.PushRemote == "" {
+		cfg.PushRemote = "origin"
+	}
+	if cfg.BranchCollision == "" {
+		cfg.BranchCollision = collisionReuse
+	}
+	if err := validateCollisionPolicy(cfg.BranchCollision); err != nil {
+		return cfg, err
+	}
+	if err := normalizeBranchNaming(&cfg.BranchNaming); err != nil {
+		return cfg, err
+	}
+	switch cfg.Push {
+	case "":
+		cfg.Push = pushOnCreate
+	case pushNever, pushOnCreate, pushOnPR:
+	default:
+		return cfg, fmt.Errorf("invalid push setting: %q (expected one of: %s, %s, %s)", cfg.Push, pushNever, pushOnCreate, pushOnPR)
+	}
+	if len(cfg.MergeStrategies) == 0 {
+		cfg.MergeStrategies = defaultMergeStrategies
+	}
+	if err := validateMergeStrategies(cfg.MergeStrategies); err != nil {
+		return cfg, err
+	}
+	if err := validateProtectedBranches(cfg.ProtectedBranches); err != nil {
+		return cfg, err
 	}
 	return cfg, nil
 }
@@ -795,6 +1672,15 @@ func gitRootDir() (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// lockNote formats the reason a worktree was locked with for messages, e.g.
+// " (reason: on a USB drive)", or "" when none was given.
+func lockNote(e worktreeEntry) string {
+	if e.lockReason == "" {
+		return ""
+	}
+	return " (reason: " + e.lockReason + ")"
+}
+
 func parseWorktreeList(raw string) []worktreeEntry {
 	var out []worktreeEntry
 	var cur worktreeEntry
@@ -814,6 +1700,10 @@ func parseWorktreeList(raw string) []worktreeEntry {
 		if strings.HasPrefix(line, "branch ") {
 			cur.branch = strings.TrimPrefix(line, "branch ")
 		}
+		if line == "locked" || strings.HasPrefix(line, "locked ") {
+			cur.locked = true
+			cur.lockReason = strings.TrimSpace(strings.TrimPrefix(line, "locked"))
+		}
 	}
 	if cur.path != "" {
 		out = append(out, cur)
@@ -833,6 +1723,23 @@ func runCmdCapture(dir, name string, args ...string) (string, error) {
 	return buf.String(), err
 }
 
+// runCmdCaptureContext is runCmdCapture bound to ctx. Once ctx is done the
+// process is killed, and output pipes held open by its children are closed
+// after a grace period so a hung CLI cannot block wtx.
+func runCmdCaptureContext(ctx context.Context, dir string, env []string, name string, args ...string) (string, error) {
+	var buf bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &buf
+	cmd.Stderr = &buf
+	cmd.Env = append(os.Environ(), env...)
+	cmd.WaitDelay = 2 * time.Second
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	err := cmd.Run()
+	return buf.String(), err
+}
+
 func runCmdStream(dir, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	cmd.Stdout = os.Stdout
@@ -844,6 +1751,18 @@ func runCmdStream(dir, name string, args ...string) error {
 	return cmd.Run()
 }
 
+func runCmdStreamEnv(dir string, env []string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+	cmd.Env = append(os.Environ(), env...)
+	if dir != "" {
+		cmd.Dir = dir
+	}
+	return cmd.Run()
+}
+
 func runCmd(name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	cmd.Stdout = io.Discard
@@ -871,21 +1790,51 @@ func commandExists(name string) bool {
 	return err == nil
 }
 
+// maxPromptBytes caps a prompt passed to an agent as a single command-line
+// argument; Linux rejects arguments over 128 KiB.
+const maxPromptBytes = 100_000
+
+// truncateUTF8 shortens s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// replaceTemplates expands the placeholders in vars within each value. The
+// expansion is a single pass, so placeholders inside substituted text, such
+// as a task mentioning {branch}, are left alone.
 func replaceTemplates(values []string, vars map[string]string) []string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	// Longer placeholders first, so none is shadowed by a prefix of it.
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k, vars[k])
+	}
+	r := strings.NewReplacer(pairs...)
 	out := make([]string, 0, len(values))
 	for _, v := range values {
-		r := v
-		for k, x := range vars {
-			r = strings.ReplaceAll(r, k, x)
-		}
-		out = append(out, r)
+		out = append(out, r.Replace(v))
 	}
 	return out
 }
 
 func promptOptional(label string) string {
 	fmt.Print(label)
-	s, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	s, _ := stdinReader.ReadString('\n')
 	return strings.TrimSpace(s)
 }
 
@@ -913,9 +1862,9 @@ func promptYesNoDefault(label string, defaultYes bool) bool {
 	return v == "y" || v == "yes"
 }
 
-func promptBaseBranch(defaultBranch string) string {
-	_ = runCmd("git", "fetch", "origin", "--prune")
-	branches, err := recentRemoteBranches("origin", 10)
+func promptBaseBranch(remote, defaultBranch string) string {
+	_ = runCmd("git", "fetch", remote, "--prune")
+	branches, err := recentRemoteBranches(remote, 10)
 	if err != nil || len(branches) == 0 {
 		return promptDefault("Base branch ["+defaultBranch+"]: ", defaultBranch)
 	}
@@ -925,7 +1874,7 @@ func promptBaseBranch(defaultBranch string) string {
 		fmt.Printf("  %d) %s\n", i+1, b)
 	}
 	fmt.Printf("Enter number or branch name [%s]: ", defaultBranch)
-	in, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	in, _ := stdinReader.ReadString('\n')
 	in = strings.TrimSpace(in)
 	if in == "" {
 		return defaultBranch
@@ -980,17 +1929,24 @@ func recentRemoteBranches(remote string, limit int) ([]string, error) {
 	return result, nil
 }
 
-func detectDefaultBaseBranch() string {
-	if out, err := runCmdCapture("", "gh", "repo", "view", "--json", "defaultBranchRef", "-q", ".defaultBranchRef.name"); err == nil {
+func detectDefaultBaseBranch(cfg config) string {
+	viewArgs := []string{"repo", "view"}
+	if isForkWorkflow(cfg) {
+		if slug := remoteRepoSlug(cfg.BaseRemote); slug != "" {
+			viewArgs = append(viewArgs, slug)
+		}
+	}
+	viewArgs = append(viewArgs, "--json", "defaultBranchRef", "-q", ".defaultBranchRef.name")
+	if out, err := runCmdCapture("", "gh", viewArgs...); err == nil {
 		v := strings.TrimSpace(out)
 		if v != "" {
 			return v
 		}
 	}
 
-	if out, err := runCmdCapture("", "git", "symbolic-ref", "--short", "refs/remotes/origin/HEAD"); err == nil {
+	if out, err := runCmdCapture("", "git", "symbolic-ref", "--short", "refs/remotes/"+cfg.BaseRemote+"/HEAD"); err == nil {
 		v := strings.TrimSpace(out)
-		v = strings.TrimPrefix(v, "origin/")
+		v = strings.TrimPrefix(v, cfg.BaseRemote+"/")
 		if v != "" {
 			return v
 		}
@@ -1007,13 +1963,17 @@ func isAllowedLLM(cfg config, v string) bool {
 	return false
 }
 
+// normalizeLLM returns the llm.allowed entry matching llm case-insensitively,
+// or "" when there is none.
 func normalizeLLM(cfg config, llm string) string {
-	v := strings.ToLower(strings.TrimSpace(llm))
+	v := strings.TrimSpace(llm)
 	if v == "" {
 		return ""
 	}
-	if isAllowedLLM(cfg, v) {
-		return v
+	for _, x := range cfg.LLM.Allowed {
+		if strings.EqualFold(x, v) {
+			return x
+		}
 	}
 	return ""
 }
@@ -1037,6 +1997,29 @@ func copyFile(src, dst string) error {
 	return out.Close()
 }
 
+// isProtectedBranch reports whether branch is the main branch or matches one
+// of the protectedBranches glob patterns. Protected branches are never removed.
+func isProtectedBranch(cfg config, branch string) bool {
+	if branch == cfg.MainBranch {
+		return true
+	}
+	for _, p := range cfg.ProtectedBranches {
+		if ok, _ := path.Match(p, branch); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func validateProtectedBranches(patterns []string) error {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern in protectedBranches: %q", p)
+		}
+	}
+	return nil
+}
+
 func isDir(path string) bool {
 	info, err := os.Stat(path)
 	return err == nil && info.IsDir()
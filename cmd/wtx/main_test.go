@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWorktreeList(t *testing.T) {
+	raw := `worktree /repo
+HEAD 1111111111111111111111111111111111111111
+branch refs/heads/main
+
+worktree /repo/.worktrees/feature__login
+HEAD 2222222222222222222222222222222222222222
+branch refs/heads/feature/login
+locked
+
+worktree /repo/.worktrees/detached
+HEAD 3333333333333333333333333333333333333333
+detached
+locked moved to a USB disk
+
+worktree /repo/.worktrees/bare
+bare
+`
+	want := []worktreeEntry{
+		{path: "/repo", branch: "refs/heads/main"},
+		{path: "/repo/.worktrees/feature__login", branch: "refs/heads/feature/login", locked: true},
+		{path: "/repo/.worktrees/detached", locked: true, lockReason: "moved to a USB disk"},
+		{path: "/repo/.worktrees/bare"},
+	}
+	if got := parseWorktreeList(raw); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWorktreeList() = %+v, want %+v", got, want)
+	}
+
+	for _, raw := range []string{"", "\n\n", "HEAD 1111111111111111111111111111111111111111\n"} {
+		if got := parseWorktreeList(raw); len(got) != 0 {
+			t.Errorf("parseWorktreeList(%q) = %+v, want none", raw, got)
+		}
+	}
+}
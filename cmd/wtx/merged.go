@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Merge detection strategies understood by the mergeStrategies config key.
+const (
+	mergeStrategyAncestry = "ancestry"
+	mergeStrategyPatchID  = "patch-id"
+	mergeStrategyTree     = "tree"
+	mergeStrategyForge    = "forge"
+)
+
+var defaultMergeStrategies = []string{
+	mergeStrategyAncestry,
+	mergeStrategyPatchID,
+	mergeStrategyTree,
+	mergeStrategyForge,
+}
+
+func validateMergeStrategies(strategies []string) error {
+	for _, s := range strategies {
+		switch s {
+		case mergeStrategyAncestry, mergeStrategyPatchID, mergeStrategyTree, mergeStrategyForge:
+		default:
+			return fmt.Errorf("unknown merge strategy in mergeStrategies: %q (expected one of: %s)", s, strings.Join(defaultMergeStrategies, ", "))
+		}
+	}
+	return nil
+}
+
+// isBranchMerged runs the configured merge strategies in order and returns the
+// name of the first one that considers branch merged into cfg.MainBranch. A
+// branch without commits of its own has nothing to merge and never counts as
+// merged, however trivially it is contained in the main branch.
+func isBranchMerged(cfg config, dir, branch string) (bool, string) {
+	if !hasOwnCommits(dir, branch) {
+		return false, ""
+	}
+	for _, s := range cfg.MergeStrategies {
+		var merged bool
+		switch s {
+		case mergeStrategyAncestry:
+			merged = runCmdIn(dir, "git", "merge-base", "--is-ancestor", branch, cfg.MainBranch) == nil
+		case mergeStrategyPatchID:
+			merged = isBranchPatchMerged(dir, cfg.MainBranch, branch)
+		case mergeStrategyTree:
+			merged = mergeTreeSupported() && isBranchTreeMerged(dir, cfg.MainBranch, branch)
+		case mergeStrategyForge:
+			merged = isBranchSquashMerged(cfg, branch)
+		}
+		if merged {
+			return true, s
+		}
+	}
+	return false, ""
+}
+
+// hasOwnCommits reports whether branch has commits beyond the commit wtx
+// created it at. Branches wtx did not create, such as ones checked out with
+// co or review, have no recorded start and are assumed to have some.
+func hasOwnCommits(dir, branch string) bool {
+	meta, ok := loadWorktreeMeta(dir, branch)
+	if !ok || meta.StartCommit == "" {
+		return true
+	}
+	out, err := runCmdCapture(dir, "git", "rev-list", "--count", meta.StartCommit+"..refs/heads/"+branch)
+	return err != nil || strings.TrimSpace(out) != "0"
+}
+
+// isBranchPatchMerged checks whether the changes of branch already exist in
+// main by patch-id. Rebase merges are caught by git cherry on the branch
+// itself; squash merges by squashing the branch into a synthetic commit on top
+// of the merge base and checking that single commit instead.
+func isBranchPatchMerged(dir, main, branch string) bool {
+	out, err := runCmdCapture(dir, "git", "cherry", main, branch)
+	if err != nil {
+		return false
+	}
+	if allCherryPicked(out) {
+		return true
+	}
+
+	mergeBase, err := runCmdCapture(dir, "git", "merge-base", main, branch)
+	if err != nil {
+		return false
+	}
+	squashed, err := runCmdCapture(
+		dir,
+		"git", "-c", "user.name=wtx", "-c", "user.email=wtx@localhost",
+		"commit-tree", branch+"^{tree}", "-p", strings.TrimSpace(mergeBase), "-m", "wtx synthetic squash",
+	)
+	if err != nil {
+		return false
+	}
+	out, err = runCmdCapture(dir, "git", "cherry", main, strings.TrimSpace(squashed))
+	if err != nil {
+		return false
+	}
+	return allCherryPicked(out)
+}
+
+// allCherryPicked reports whether git cherry output lists at least one commit
+// and every commit is marked as already present upstream ("-").
+func allCherryPicked(out string) bool {
+	found := false
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "-") {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// mergeTreeSupported reports whether git has merge-tree --write-tree, which
+// the tree strategy needs (git 2.38 or newer). Without it a warning is printed
+// once and the strategy is skipped.
+var mergeTreeSupported = sync.OnceValue(func() bool {
+	out, err := runCmdCapture("", "git", "version")
+	if err == nil && gitVersionAtLeast(out, 2, 38) {
+		return true
+	}
+	fmt.Printf("Warning: the %q merge strategy needs git 2.38 or newer (found %s); skipping it.\n", mergeStrategyTree, strings.TrimSpace(out))
+	return false
+})
+
+// gitVersionAtLeast parses `git version` output such as "git version 2.39.3
+// (Apple Git-146)" and compares it with major.minor.
+func gitVersionAtLeast(out string, major, minor int) bool {
+	fields := strings.Fields(out)
+	if len(fields) < 3 {
+		return false
+	}
+	parts := strings.SplitN(fields[2], ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	ma, err1 := strconv.Atoi(parts[0])
+	mi, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return ma > major || ma == major && mi >= minor
+}
+
+// isBranchTreeMerged checks whether merging branch into main would leave
+// main's tree unchanged, i.e. everything on the branch is already in main even
+// if main has moved on since the squash.
+func isBranchTreeMerged(dir, main, branch string) bool {
+	merged, err := runCmdCapture(dir, "git", "merge-tree", "--write-tree", main, branch)
+	if err != nil {
+		return false
+	}
+	mainTree, err := runCmdCapture(dir, "git", "rev-parse", main+"^{tree}")
+	if err != nil {
+		return false
+	}
+	lines := strings.SplitN(strings.TrimSpace(merged), "\n", 2)
+	return strings.TrimSpace(lines[0]) == strings.TrimSpace(mainTree)
+}
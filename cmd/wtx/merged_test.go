@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func TestGitVersionAtLeast(t *testing.T) {
+	tests := []struct {
+		out  string
+		want bool
+	}{
+		{"git version 2.38.0", true},
+		{"git version 2.39.3 (Apple Git-146)\n", true},
+		{"git version 3.0.0", true},
+		{"git version 2.37.7", false},
+		{"git version 1.9.5.msysgit.0", false},
+		{"git version 2.45.windows.1", true},
+		{"", false},
+		{"not git", false},
+	}
+	for _, tt := range tests {
+		if got := gitVersionAtLeast(tt.out, 2, 38); got != tt.want {
+			t.Errorf("gitVersionAtLeast(%q) = %v, want %v", tt.out, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const worktreeMetaFile = "meta.json"
+
+// worktreeMeta is what wtx remembers about a worktree it created. It is stored
+// per branch under the wtx data dir and removed together with the worktree.
+type worktreeMeta struct {
+	Branch      string            `json:"branch"`
+	Path        string            `json:"path"`
+	Base        string            `json:"base,omitempty"`
+	Remote      string            `json:"remote,omitempty"`
+	Pushed      bool              `json:"pushed"`
+	Issue       string            `json:"issue,omitempty"`
+	IssueURL    string            `json:"issueUrl,omitempty"`
+	StartCommit string            `json:"startCommit,omitempty"`
+	Naming      *branchNamingMeta `json:"naming,omitempty"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+type branchNamingMeta struct {
+	// Source is json, regex, slug, edited or existing.
+	Source      string `json:"source"`
+	Summary     string `json:"summary,omitempty"`
+	CommitTitle string `json:"commitTitle,omitempty"`
+}
+
+// worktreeMetaDir returns the metadata directory for branch in the repository
+// containing dir.
+func worktreeMetaDir(dir, branch string) (string, error) {
+	data, err := wtxDataDir(dir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(data, "worktrees", strings.ReplaceAll(branch, "/", "__")), nil
+}
+
+// loadWorktreeMeta returns the metadata for branch, or false when wtx has no
+// record of it.
+func loadWorktreeMeta(dir, branch string) (worktreeMeta, bool) {
+	var meta worktreeMeta
+	metaDir, err := worktreeMetaDir(dir, branch)
+	if err != nil {
+		return meta, false
+	}
+	raw, err := os.ReadFile(filepath.Join(metaDir, worktreeMetaFile))
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(raw, &meta); err != nil {
+		return meta, false
+	}
+	return meta, true
+}
+
+func saveWorktreeMeta(dir string, meta worktreeMeta) error {
+	metaDir, err := worktreeMetaDir(dir, meta.Branch)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(metaDir, worktreeMetaFile), raw, 0o644)
+}
+
+func removeWorktreeMeta(dir, branch string) error {
+	metaDir, err := worktreeMetaDir(dir, branch)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(metaDir); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// branchNamingCfg is the branch naming policy applied to generated names.
+type branchNamingCfg struct {
+	// Prefixes are the allowed type prefixes such as "feature/" or "fix/".
+	Prefixes []string `json:"prefixes"`
+	// DefaultPrefix is added to names without an allowed prefix.
+	DefaultPrefix string `json:"defaultPrefix"`
+	MaxLength     int    `json:"maxLength"`
+	// UserPrefix is put in front of the whole name; {user} expands to the
+	// local part of git's user.email (or $USER).
+	UserPrefix string `json:"userPrefix"`
+	// DateSuffix is a Go time layout such as "20060102" appended as "-<date>".
+	DateSuffix string `json:"dateSuffix"`
+	// Pattern is a regular expression the final name must match.
+	Pattern string `json:"pattern"`
+	// Template lays out the name from {type}, {issue} and {slug}. Separators
+	// around {issue} are dropped when there is no issue.
+	Template string `json:"template"`
+	// RequireIssue makes creation fail when no issue key is given or found.
+	RequireIssue bool `json:"requireIssue"`
+	// IssueKeyPrefixes are the issue-tracker project keys, such as "PROJ",
+	// whose keys (PROJ-123) are detected in tasks. Without any, only GitHub
+	// issue numbers (#123) are detected.
+	IssueKeyPrefixes []string `json:"issueKeyPrefixes"`
+	// LabelPrefixes maps issue labels to branch prefixes for wtx issue.
+	LabelPrefixes map[string]string `json:"labelPrefixes"`
+	// MaxWords limits the words of a slug made without the AI.
+	MaxWords int `json:"maxWords"`
+	// Glossary adds translations for the offline slugger, e.g. Japanese
+	// terms that cannot be romanized meaningfully.
+	Glossary map[string]string `json:"glossary"`
+}
+
+const defaultBranchTemplate = "{type}/{issue}-{slug}"
+
+var (
+	issueNumberRe    = regexpMustCompile(`(?:^|[\s(\[])#([0-9]+)\b`)
+	issueKeyPrefixRe = regexpMustCompile(`^[A-Za-z][A-Za-z0-9_]*$`)
+)
+
+var defaultBranchPrefixes = []string{"feature/", "bugfix/", "fix/", "chore/", "refactor/", "docs/", "test/"}
+
+var defaultLabelPrefixes = map[string]string{
+	"bug":           "fix/",
+	"documentation": "docs/",
+	"enhancement":   "feature/",
+	"refactor":      "refactor/",
+	"chore":         "chore/",
+}
+
+// normalizeBranchNaming fills in defaults and validates the policy.
+func normalizeBranchNaming(n *branchNamingCfg) error {
+	if len(n.Prefixes) == 0 {
+		n.Prefixes = defaultBranchPrefixes
+	}
+	prefixes := make([]string, 0, len(n.Prefixes))
+	for _, p := range n.Prefixes {
+		p = strings.Trim(strings.ToLower(strings.TrimSpace(p)), "/")
+		if p == "" {
+			continue
+		}
+		prefixes = append(prefixes, p+"/")
+	}
+	n.Prefixes = prefixes
+
+	n.DefaultPrefix = strings.Trim(strings.ToLower(strings.TrimSpace(n.DefaultPrefix)), "/")
+	if n.DefaultPrefix == "" {
+		n.DefaultPrefix = "feature"
+	}
+	n.DefaultPrefix += "/"
+
+	if n.MaxLength == 0 {
+		n.MaxLength = 120
+	}
+	if n.MaxLength < 0 {
+		return fmt.Errorf("invalid branchNaming.maxLength: %d", n.MaxLength)
+	}
+	if n.Pattern != "" {
+		if _, err := regexp.Compile(n.Pattern); err != nil {
+			return fmt.Errorf("invalid branchNaming.pattern: %w", err)
+		}
+	}
+	if strings.TrimSpace(n.Template) == "" {
+		n.Template = defaultBranchTemplate
+	}
+	if !strings.Contains(n.Template, "{slug}") {
+		return fmt.Errorf("invalid branchNaming.template: %q must contain {slug}", n.Template)
+	}
+	keys := make([]string, 0, len(n.IssueKeyPrefixes))
+	for _, k := range n.IssueKeyPrefixes {
+		k = strings.TrimSuffix(strings.TrimSpace(k), "-")
+		if k == "" {
+			continue
+		}
+		if !issueKeyPrefixRe.MatchString(k) {
+			return fmt.Errorf("invalid branchNaming.issueKeyPrefixes: %q", k)
+		}
+		keys = append(keys, k)
+	}
+	n.IssueKeyPrefixes = keys
+
+	if n.LabelPrefixes == nil {
+		n.LabelPrefixes = defaultLabelPrefixes
+	}
+	labels := make(map[string]string, len(n.LabelPrefixes))
+	for label, p := range n.LabelPrefixes {
+		p = strings.Trim(strings.ToLower(strings.TrimSpace(p)), "/")
+		if p == "" {
+			return fmt.Errorf("invalid branchNaming.labelPrefixes: empty prefix for label %q", label)
+		}
+		labels[strings.ToLower(strings.TrimSpace(label))] = p + "/"
+	}
+	n.LabelPrefixes = labels
+
+	if n.MaxWords == 0 {
+		n.MaxWords = 6
+	}
+	if n.MaxWords < 0 {
+		return fmt.Errorf("invalid branchNaming.maxWords: %d", n.MaxWords)
+	}
+	glossary := make(map[string]string, len(defaultSlugGlossary)+len(n.Glossary))
+	for k, v := range defaultSlugGlossary {
+		glossary[k] = v
+	}
+	for k, v := range n.Glossary {
+		if utf8.RuneCountInString(k) > maxGlossaryKeyLen {
+			return fmt.Errorf("invalid branchNaming.glossary: %q is longer than %d characters", k, maxGlossaryKeyLen)
+		}
+		glossary[k] = strings.ToLower(v)
+	}
+	n.Glossary = glossary
+	return nil
+}
+
+// labelPrefix returns the branch prefix for the first label that has one.
+func labelPrefix(n branchNamingCfg, labels []string) string {
+	for _, l := range labels {
+		if p, ok := n.LabelPrefixes[strings.ToLower(strings.TrimSpace(l))]; ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// detectIssue returns the first issue-tracker key (PROJ-123) with one of the
+// configured project prefixes or GitHub issue number (#123) mentioned in text,
+// or "". Keys of unknown projects are ignored so that terms such as UTF-8 or
+// SHA-256 are not taken for issues.
+func detectIssue(n branchNamingCfg, text string) string {
+	if len(n.IssueKeyPrefixes) > 0 {
+		quoted := make([]string, len(n.IssueKeyPrefixes))
+		for i, k := range n.IssueKeyPrefixes {
+			quoted[i] = regexp.QuoteMeta(k)
+		}
+		re := regexpMustCompile(`\b(?:` + strings.Join(quoted, "|") + `)-[0-9]+\b`)
+		if m := re.FindString(text); m != "" {
+			return m
+		}
+	}
+	if m := issueNumberRe.FindStringSubmatch(text); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// normalizeIssue cleans a user-supplied issue reference without changing the
+// case of the key.
+func normalizeIssue(v string) string {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "#")
+	return strings.Trim(regexpReplace(v, `[^A-Za-z0-9-]+`, "-"), "-")
+}
+
+// applyBranchTemplate lays out a sanitized branch name according to the
+// naming template. It runs after sanitizeBranch so the issue key keeps its
+// case. A non-empty prefix replaces the type the name came with.
+func applyBranchTemplate(n branchNamingCfg, branch, issue, prefix string) string {
+	typ := strings.TrimSuffix(n.DefaultPrefix, "/")
+	slug := branch
+	for _, p := range n.Prefixes {
+		if rest, ok := strings.CutPrefix(branch, p); ok {
+			typ, slug = strings.TrimSuffix(p, "/"), rest
+			break
+		}
+	}
+	if prefix != "" {
+		typ = strings.TrimSuffix(prefix, "/")
+	}
+	if issue != "" {
+		// The AI or the slug fallback may already have put the key in.
+		key := regexp.QuoteMeta(strings.ToLower(issue))
+		slug = strings.Trim(regexpReplace(slug, `(^|-)`+key+`(-|$)`, "-"), "-")
+	}
+	if slug == "" {
+		slug = "task"
+	}
+
+	s := replaceTemplates([]string{n.Template}, map[string]string{
+		"{type}":  typ,
+		"{issue}": issue,
+		"{slug}":  slug,
+	})[0]
+	s = regexpReplace(s, `-+`, "-")
+	s = regexpReplace(s, `/+`, "/")
+	s = regexpReplace(s, `-?/-?`, "/")
+	return strings.Trim(s, "-/")
+}
+
+func hasBranchPrefix(n branchNamingCfg, s string) bool {
+	for _, p := range n.Prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// finalizeBranch applies the parts of the naming policy that must only be
+// applied once to a sanitized name: user prefix, date suffix, length limit
+// and the required pattern.
+func finalizeBranch(n branchNamingCfg, name string) (string, error) {
+	prefix := ""
+	if n.UserPrefix != "" {
+		prefix = strings.ReplaceAll(n.UserPrefix, "{user}", branchUser())
+	}
+	suffix := ""
+	if n.DateSuffix != "" {
+		suffix = "-" + time.Now().Format(n.DateSuffix)
+	}
+
+	if room := n.MaxLength - len(prefix) - len(suffix); len(name) > room {
+		if room <= 0 {
+			return "", fmt.Errorf("branchNaming.maxLength %d leaves no room for the name", n.MaxLength)
+		}
+		name = strings.TrimRight(name[:room], "-/")
+	}
+	name = prefix + name + suffix
+	return name, checkBranchPattern(n, name)
+}
+
+func checkBranchPattern(n branchNamingCfg, name string) error {
+	if n.Pattern != "" && !regexpMustCompile(n.Pattern).MatchString(name) {
+		return fmt.Errorf("branch name %q does not match branchNaming.pattern %q", name, n.Pattern)
+	}
+	return nil
+}
+
+// confirmBranchName shows the proposed branch name and lets the user accept
+// it, edit it, regenerate it with the AI or pick one of the candidates seen so
+// far. Edited names are taken as typed; they only have to be valid and match
+// the naming pattern.
+func confirmBranchName(n branchNamingCfg, candidates []branchSuggestion, generate func() ([]branchSuggestion, error)) (branchSuggestion, error) {
+	current := candidates[0]
+	for {
+		fmt.Printf("Proposed branch name: %s\n", current.Branch)
+		if current.Summary != "" {
+			fmt.Printf("  %s\n", current.Summary)
+		}
+		if len(candidates) > 1 {
+			for i, c := range candidates {
+				fmt.Printf("  %d) %s\n", i+1, c.Branch)
+			}
+		}
+		choice := strings.ToLower(promptOptional("Accept [Enter], (e)dit, (r)egenerate or pick a number: "))
+		switch choice {
+		case "", "y", "yes":
+			return current, nil
+		case "e", "edit":
+			v := strings.TrimSpace(promptDefault("Branch name ["+current.Branch+"]: ", current.Branch))
+			if err := validateBranchName(n, v); err != nil {
+				fmt.Printf("Invalid branch name: %v\n", err)
+				continue
+			}
+			if v != current.Branch {
+				current.Branch, current.Source = v, branchSourceEdited
+			}
+			return current, nil
+		case "r", "regenerate":
+			fresh, err := generate()
+			if err != nil {
+				fmt.Printf("Could not generate a branch name: %v\n", err)
+				continue
+			}
+			added := false
+			for _, c := range fresh {
+				if !slices.ContainsFunc(candidates, func(x branchSuggestion) bool { return x.Branch == c.Branch }) {
+					candidates = append(candidates, c)
+					added = true
+				}
+			}
+			if !added {
+				fmt.Println("No new name was generated.")
+			}
+			current = fresh[0]
+		default:
+			i, err := strconv.Atoi(choice)
+			if err != nil || i < 1 || i > len(candidates) {
+				fmt.Printf("Unknown choice: %s\n", choice)
+				continue
+			}
+			current = candidates[i-1]
+		}
+	}
+}
+
+func validateBranchName(n branchNamingCfg, name string) error {
+	if name == "" {
+		return errors.New("empty branch name")
+	}
+	if runCmd("git", "check-ref-format", "--branch", name) != nil {
+		return fmt.Errorf("%q is not a valid branch name", name)
+	}
+	return checkBranchPattern(n, name)
+}
+
+// branchUser returns a branch-safe user name for {user}.
+func branchUser() string {
+	user := ""
+	if out, err := runCmdCapture("", "git", "config", "user.email"); err == nil {
+		user, _, _ = strings.Cut(strings.TrimSpace(out), "@")
+	}
+	if user == "" {
+		user = os.Getenv("USER")
+	}
+	user = strings.Trim(regexpReplace(strings.ToLower(user), `[^a-z0-9]+`, "-"), "-")
+	if user == "" {
+		user = "user"
+	}
+	return user
+}
+
+// sanitizeBranch turns v into a valid branch name with one of the allowed
+// prefixes. Length limits are applied later by finalizeBranch.
+func sanitizeBranch(n branchNamingCfg, v string) string {
+	s := strings.ToLower(strings.TrimSpace(v))
+	s = regexpReplace(s, `[^a-z0-9/-]+`, "-")
+	s = regexpReplace(s, `-+`, "-")
+	s = regexpReplace(s, `/+`, "/")
+	s = strings.Trim(s, "-")
+	s = strings.Trim(s, "/")
+	if s == "" {
+		return ""
+	}
+	if !hasBranchPrefix(n, s) {
+		s = n.DefaultPrefix + s
+	}
+	return s
+}
+
+// extractBranchCandidates finds the prefixed branch names in AI output, the
+// last one first, falling back to the last token that sanitizes to something
+// usable.
+func extractBranchCandidates(n branchNamingCfg, out string) []string {
+	text := strings.ReplaceAll(out, "\r", "\n")
+	alts := make([]string, 0, len(n.Prefixes))
+	for _, p := range n.Prefixes {
+		alts = append(alts, regexp.QuoteMeta(strings.TrimSuffix(p, "/")))
+	}
+	re := regexpMustCompile(`(?m)\b(` + strings.Join(alts, "|") + `)\/[a-z0-9][a-z0-9\-/]+\b`)
+	matches := re.FindAllString(strings.ToLower(text), -1)
+	var found []string
+	for i := len(matches) - 1; i >= 0; i-- {
+		v := sanitizeBranch(n, matches[i])
+		if v != "" && len(v) <= n.MaxLength && !slices.Contains(found, v) {
+			found = append(found, v)
+		}
+	}
+	if len(found) > 0 {
+		return found
+	}
+
+	parts := strings.Fields(strings.ToLower(text))
+	for i := len(parts) - 1; i >= 0; i-- {
+		token := strings.Trim(parts[i], "\"'`.,:;[](){}<>")
+		v := sanitizeBranch(n, token)
+		if v != "" && len(v) <= n.MaxLength {
+			return []string{v}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestSanitizeBranch(t *testing.T) {
+	n := branchNamingCfg{}
+	if err := normalizeBranchNaming(&n); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"feature/add-login", "feature/add-login"},
+		{"Fix/Crash On Start", "fix/crash-on-start"},
+		{"add login form", "feature/add-login-form"},
+		{"  chore//bump__deps-- ", "chore/bump-deps"},
+		{"/docs/readme/", "docs/readme"},
+		{"feat/add-x", "feature/feat/add-x"},
+		{"ログイン", ""},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := sanitizeBranch(n, tt.in); got != tt.want {
+			t.Errorf("sanitizeBranch(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDetectIssue(t *testing.T) {
+	n := branchNamingCfg{IssueKeyPrefixes: []string{"PROJ"}}
+	if err := normalizeBranchNaming(&n); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"PROJ-123 add login form", "PROJ-123"},
+		{"fix UTF-8 handling for PROJ-7", "PROJ-7"},
+		{"fix #42 crash on start", "42"},
+		{"support SHA-256 and ISO-8601", ""},
+		{"OTHER-1 is not ours", ""},
+		{"no issue here", ""},
+	}
+	for _, tt := range tests {
+		if got := detectIssue(n, tt.text); got != tt.want {
+			t.Errorf("detectIssue(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// editorScissors separates editable text from the help below it, like git's
+// --cleanup=scissors, so Markdown headings in the text survive.
+const editorScissors = "# ------------------------ >8 ------------------------"
+
+const taskEditorHelp = `# Describe the task above. Everything from the line above down is ignored.
+# Save and close the editor to continue; an empty task aborts.`
+
+const initialPromptEditorHelp = `# Write the initial prompt for the AI above; it starts as the task
+# description. Everything from the line above down is ignored.
+# Save and close the editor to continue; an empty prompt aborts.`
+
+// readTextArg returns v, or all of stdin when v is "-".
+func readTextArg(v string) (string, error) {
+	if v != "-" {
+		return v, nil
+	}
+	raw, err := io.ReadAll(stdinReader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// readPromptFile reads a prompt from path, or from stdin when path is "-".
+func readPromptFile(path string) (string, error) {
+	if path == "-" {
+		return readTextArg(path)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(raw)), nil
+}
+
+// promptTask asks for the task description on one line, or opens the editor
+// when the answer is empty and stdin is a terminal.
+func promptTask(cfg config) (string, error) {
+	if !isInteractive() {
+		v := promptOptional("Task description: ")
+		if v == "" {
+			return "", errors.New("no task description provided")
+		}
+		return v, nil
+	}
+	v := promptOptional("Task description (Enter to open editor): ")
+	if v != "" {
+		return v, nil
+	}
+	return editTask(cfg, "")
+}
+
+// editTask opens the editor on task, or on llm.taskEditorTemplate when task is
+// empty.
+func editTask(cfg config, task string) (string, error) {
+	if task == "" {
+		task = cfg.LLM.TaskEditorTemplate
+	}
+	v, err := editText(task, taskEditorHelp)
+	if err != nil {
+		return "", err
+	}
+	if v == "" || v == strings.TrimSpace(cfg.LLM.TaskEditorTemplate) {
+		return "", errors.New("no task description provided")
+	}
+	return v, nil
+}
+
+// editInitialPrompt opens the editor pre-filled with the task so the AI can
+// be given a longer or different first prompt.
+func editInitialPrompt(task string) (string, error) {
+	v, err := editText(task, initialPromptEditorHelp)
+	if err != nil {
+		return "", err
+	}
+	if v == "" {
+		return "", errors.New("empty initial prompt")
+	}
+	return v, nil
+}
+
+// editText lets the user edit text in $VISUAL or $EDITOR (vi by default) and
+// returns the result without the help below the scissors line.
+func editText(text, help string) (string, error) {
+	f, err := os.CreateTemp("", "wtx-*.md")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	content := strings.TrimRight(text, "\n") + "\n\n" + editorScissors + "\n" + help + "\n"
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	if err := runEditor(f.Name()); err != nil {
+		return "", err
+	}
+	raw, err := os.ReadFile(f.Name())
+	if err != nil {
+		return "", err
+	}
+	edited, _, _ := strings.Cut(string(raw), editorScissors)
+	return strings.TrimSpace(edited), nil
+}
+
+func runEditor(path string) error {
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		fields := strings.Fields(editor)
+		cmd = exec.Command(fields[0], append(fields[1:], path)...)
+	} else {
+		// Through the shell so editors with arguments ("code --wait") work.
+		cmd = exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	}
+	// The editor needs the terminal even when stdin was used for input.
+	tty, err := os.Open("/dev/tty")
+	if err == nil {
+		defer tty.Close()
+		cmd.Stdin = tty
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Values of llm.providers.*.type.
+const providerOpenAI = "openai"
+
+// providerClient bounds provider requests even when llm.branchNameTimeout is
+// 0, so a hung server cannot block worktree creation.
+var providerClient = &http.Client{Timeout: 2 * time.Minute}
+
+// llmProviderCfg describes an HTTP model endpoint. The openai type speaks the
+// OpenAI chat completions API, which Ollama, llama.cpp and vLLM serve too.
+type llmProviderCfg struct {
+	Type    string `json:"type"`
+	BaseURL string `json:"baseURL"`
+	Model   string `json:"model"`
+	// APIKeyEnv names the environment variable holding the API key. Local
+	// servers usually need none.
+	APIKeyEnv string `json:"apiKeyEnv"`
+}
+
+func validateProviders(l llmCfg) error {
+	for name, p := range l.Providers {
+		if p.Type != providerOpenAI {
+			return fmt.Errorf("invalid llm.providers.%s.type: %q (expected %s)", name, p.Type, providerOpenAI)
+		}
+		if strings.TrimSpace(p.BaseURL) == "" {
+			return fmt.Errorf("llm.providers.%s.baseURL is required", name)
+		}
+		if strings.TrimSpace(p.Model) == "" {
+			return fmt.Errorf("llm.providers.%s.model is required", name)
+		}
+	}
+	if l.BranchNameProvider != "" {
+		if _, ok := l.Providers[l.BranchNameProvider]; !ok {
+			return fmt.Errorf("llm.branchNameProvider %q is not defined in llm.providers", l.BranchNameProvider)
+		}
+	}
+	return nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatResponseFormat struct {
+	Type string `json:"type"`
+}
+
+type chatCompletionRequest struct {
+	Model          string              `json:"model"`
+	Messages       []chatMessage       `json:"messages"`
+	Temperature    float64             `json:"temperature"`
+	ResponseFormat *chatResponseFormat `json:"response_format,omitempty"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// chatCompletion sends prompt as a single user message and returns the reply.
+// With jsonMode the server is asked for a JSON object.
+func chatCompletion(ctx context.Context, p llmProviderCfg, prompt string, jsonMode bool) (string, error) {
+	reqBody := chatCompletionRequest{
+		Model:       p.Model,
+		Messages:    []chatMessage{{Role: "user", Content: prompt}},
+		Temperature: 0.2,
+	}
+	if jsonMode {
+		reqBody.ResponseFormat = &chatResponseFormat{Type: "json_object"}
+	}
+	raw, err := json.Marshal(reqBody)
+	if err != nil {
+		return "", err
+	}
+
+	url := strings.TrimSuffix(p.BaseURL, "/") + "/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.APIKeyEnv != "" {
+		key := os.Getenv(p.APIKeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("%s is not set", p.APIKeyEnv)
+		}
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+
+	resp, err := providerClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+
+	var out chatCompletionResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+		}
+		return "", fmt.Errorf("invalid response from %s: %w", url, err)
+	}
+	if out.Error != nil {
+		return "", errors.New(out.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New(resp.Status)
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
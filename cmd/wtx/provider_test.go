@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatCompletion(t *testing.T) {
+	var got chatCompletionRequest
+	var gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"feature/add-login"}}]}`))
+	}))
+	defer srv.Close()
+
+	t.Setenv("WTX_TEST_API_KEY", "secret")
+	p := llmProviderCfg{Type: providerOpenAI, BaseURL: srv.URL + "/v1/", Model: "test-model", APIKeyEnv: "WTX_TEST_API_KEY"}
+
+	out, err := chatCompletion(context.Background(), p, "name this", false)
+	if err != nil {
+		t.Fatalf("chatCompletion failed: %v", err)
+	}
+	if out != "feature/add-login" {
+		t.Errorf("reply = %q, want feature/add-login", out)
+	}
+	if got.Model != "test-model" || len(got.Messages) != 1 || got.Messages[0].Role != "user" || got.Messages[0].Content != "name this" {
+		t.Errorf("unexpected request: %+v", got)
+	}
+	if got.ResponseFormat != nil {
+		t.Errorf("response_format = %+v, want none outside JSON mode", got.ResponseFormat)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want Bearer secret", gotAuth)
+	}
+
+	if _, err := chatCompletion(context.Background(), p, "name this", true); err != nil {
+		t.Fatalf("chatCompletion in JSON mode failed: %v", err)
+	}
+	if got.ResponseFormat == nil || got.ResponseFormat.Type != "json_object" {
+		t.Errorf("response_format = %+v, want json_object", got.ResponseFormat)
+	}
+}
+
+func TestChatCompletionErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{"error object", http.StatusUnauthorized, `{"error":{"message":"invalid api key"}}`, "invalid api key"},
+		{"plain text", http.StatusBadGateway, "upstream down", "502 Bad Gateway: upstream down"},
+		{"json without error", http.StatusInternalServerError, `{}`, "500 Internal Server Error"},
+		{"no choices", http.StatusOK, `{"choices":[]}`, "response has no choices"},
+		{"not json", http.StatusOK, "hello", "invalid response"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+
+			p := llmProviderCfg{Type: providerOpenAI, BaseURL: srv.URL, Model: "test-model"}
+			_, err := chatCompletion(context.Background(), p, "name this", false)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Setenv("WTX_TEST_UNSET_KEY", "")
+	p := llmProviderCfg{Type: providerOpenAI, BaseURL: "http://127.0.0.1:1", Model: "m", APIKeyEnv: "WTX_TEST_UNSET_KEY"}
+	if _, err := chatCompletion(context.Background(), p, "x", false); err == nil || !strings.Contains(err.Error(), "WTX_TEST_UNSET_KEY is not set") {
+		t.Errorf("error = %v, want missing API key", err)
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const raceChecksLogFile = "checks.log"
+
+// raceAttempt is one agent's attempt at the task in its own worktree.
+type raceAttempt struct {
+	agent    string
+	wt       worktreeEntry
+	start    string
+	created  bool // the race created the worktree, so it may remove it
+	status   agentStatus
+	diffstat string
+	checks   []checkResult
+}
+
+type checkResult struct {
+	name    string
+	passed  bool
+	skipped bool
+}
+
+// runRace runs several agents on the same task, each in its own worktree
+// from the same base, then compares the attempts and keeps the chosen one.
+func runRace(cfg config, args []string) error {
+	args, flags, err := parseFlags(args, []string{"yes", "y"}, append([]string{"agents", "n", "issue", "prompt-file", "template"}, remoteFlags...))
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	template, err := promptTemplateName(cfg, flags)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return errors.New(`task is required (example: wtx race "fix flaky test" --agents codex,claude)`)
+	}
+	task := args[0]
+	base := cfg.DefaultBaseBranch
+	if len(args) >= 2 && strings.TrimSpace(args[1]) != "" {
+		base = args[1]
+	}
+	agents, err := raceAgents(cfg, flags.value("agents"))
+	if err != nil {
+		return err
+	}
+	n := 1
+	if flags.has("n") {
+		n, err = strconv.Atoi(flags.value("n"))
+		if err != nil || n < 1 {
+			return fmt.Errorf("invalid --n: %q (expected a positive number)", flags.value("n"))
+		}
+	}
+	yes := flags.has("yes") || flags.has("y")
+
+	if task == "-" && flags.value("prompt-file") == "-" {
+		return errors.New("stdin cannot be both the task and the --prompt-file")
+	}
+	if task, err = readTextArg(task); err != nil {
+		return err
+	}
+	prompt := task
+	if flags.has("prompt-file") {
+		if prompt, err = readPromptFile(flags.value("prompt-file")); err != nil {
+			return err
+		}
+	}
+	issue := normalizeIssue(flags.value("issue"))
+	if issue == "" {
+		issue = detectIssue(cfg.BranchNaming, task)
+	}
+	if issue == "" && cfg.BranchNaming.RequireIssue {
+		return errors.New("an issue key is required; pass --issue or mention it in the task (e.g. PROJ-123 or #123)")
+	}
+
+	// One name for the task; every attempt gets it suffixed with its agent.
+	naming, err := chooseBranchName(cfg, task, agents[0], issue, "", yes)
+	if err != nil {
+		return err
+	}
+	// Attempts always get fresh branches: reusing one would let the agent
+	// work in, and a losing attempt remove, a worktree from before the race.
+	raceStart := time.Now()
+	var attempts []*raceAttempt
+	for _, agent := range agents {
+		for i := 1; i <= n; i++ {
+			s := naming
+			s.Branch += "-" + agent
+			if n > 1 {
+				s.Branch += "-" + strconv.Itoa(i)
+			}
+			wt, err := createWorktree(cfg, worktreeOptions{
+				task:        task,
+				base:        base,
+				llm:         agent,
+				onCollision: collisionSuffix,
+				issue:       issue,
+				yes:         true,
+				naming:      &s,
+			})
+			if err != nil {
+				abandonRaceAttempts(cfg, attempts)
+				return err
+			}
+			a := &raceAttempt{agent: agent, wt: wt, created: createdSince(wt, raceStart)}
+			attempts = append(attempts, a)
+			start, err := runCmdCapture(wt.path, "git", "rev-parse", "HEAD")
+			if err != nil {
+				abandonRaceAttempts(cfg, attempts)
+				return err
+			}
+			a.start = strings.TrimSpace(start)
+		}
+	}
+
+	fmt.Printf("\nRacing %d attempts...\n", len(attempts))
+	var wg sync.WaitGroup
+	for _, a := range attempts {
+		p := prompt
+		if template != "" {
+			p = renderPromptTemplate(cfg, template, promptContext{
+				task:     prompt,
+				branch:   a.wt.branch,
+				base:     base,
+				issue:    issue,
+				worktree: a.wt.path,
+			})
+		}
+		fmt.Printf("Started %s on %s\n", agentName(cfg, a.agent), a.wt.branch)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.status = runHeadlessAgent(cfg, a.agent, a.wt.branch, a.wt.path, p)
+			fmt.Printf("%s on %s: %s\n", agentName(cfg, a.agent), a.wt.branch, describeAgentStatus(a.status))
+		}()
+	}
+	wg.Wait()
+
+	// Checks run one at a time; test suites tend to share ports and caches.
+	for _, a := range attempts {
+		a.diffstat = raceDiffstat(a)
+		a.checks = runRaceChecks(cfg, a)
+	}
+	printRaceResults(cfg, attempts)
+	return keepRaceAttempt(cfg, attempts, yes)
+}
+
+// createdSince reports whether wtx's metadata says wt was created at or after
+// t.
+func createdSince(wt worktreeEntry, t time.Time) bool {
+	meta, ok := loadWorktreeMeta(wt.path, wt.branch)
+	return ok && !meta.CreatedAt.Before(t)
+}
+
+// abandonRaceAttempts removes the worktrees a race created before it failed
+// to set up the rest, and names any that could not be removed.
+func abandonRaceAttempts(cfg config, attempts []*raceAttempt) {
+	for _, a := range attempts {
+		if !a.created {
+			continue
+		}
+		fmt.Printf("Removing attempt %s after the failed setup...\n", a.wt.branch)
+		if err := runRemove(cfg, []string{a.wt.path, "--force", "--yes"}); err != nil {
+			fmt.Printf("Warning: could not remove %s: %v\n", a.wt.path, err)
+		}
+	}
+}
+
+// raceAgents parses --agents, a comma-separated list that defaults to
+// llm.default. Every agent must be able to run headless.
+func raceAgents(cfg config, v string) ([]string, error) {
+	if strings.TrimSpace(v) == "" {
+		v = cfg.LLM.Default
+	}
+	if strings.TrimSpace(v) == "" {
+		return nil, agentSelectionError(cfg)
+	}
+	var agents []string
+	for _, name := range strings.Split(v, ",") {
+		llm := normalizeLLM(cfg, name)
+		if llm == "" {
+			return nil, fmt.Errorf("invalid agent %q (expected one of: %s)", strings.TrimSpace(name), strings.Join(cfg.LLM.Allowed, ", "))
+		}
+		if _, err := headlessArgs(cfg, llm, ""); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(agents, llm) {
+			agents = append(agents, llm)
+		}
+	}
+	return agents, nil
+}
+
+// raceDiffstat summarizes what the attempt changed since its worktree was
+// created, committed or not.
+func raceDiffstat(a *raceAttempt) string {
+	stat, err := runCmdCapture(a.wt.path, "git", "diff", "--shortstat", a.start)
+	if err != nil {
+		return "unknown"
+	}
+	parts := []string{}
+	if s := strings.TrimSpace(stat); s != "" {
+		parts = append(parts, s)
+	}
+	untracked, _ := runCmdCapture(a.wt.path, "git", "ls-files", "--others", "--exclude-standard")
+	if untracked = strings.TrimSpace(untracked); untracked != "" {
+		n := strings.Count(untracked, "\n") + 1
+		parts = append(parts, fmt.Sprintf("%d untracked files", n))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// runRaceChecks runs raceChecks in the attempt's worktree. Their output goes
+// to checks.log in the worktree's metadata directory.
+func runRaceChecks(cfg config, a *raceAttempt) []checkResult {
+	if len(cfg.RaceChecks) == 0 {
+		return nil
+	}
+	metaDir, err := worktreeMetaDir(a.wt.path, a.wt.branch)
+	if err != nil {
+		fmt.Printf("Warning: cannot run checks on %s: %v\n", a.wt.branch, err)
+		return nil
+	}
+	if err := os.MkdirAll(metaDir, 0o755); err != nil {
+		fmt.Printf("Warning: cannot run checks on %s: %v\n", a.wt.branch, err)
+		return nil
+	}
+	log, err := os.Create(filepath.Join(metaDir, raceChecksLogFile))
+	if err != nil {
+		fmt.Printf("Warning: cannot run checks on %s: %v\n", a.wt.branch, err)
+		return nil
+	}
+	defer log.Close()
+
+	var results []checkResult
+	for _, hook := range cfg.RaceChecks {
+		if len(hook.Command) == 0 {
+			continue
+		}
+		name := strings.TrimSpace(hook.Name)
+		if name == "" {
+			name = strings.Join(hook.Command, " ")
+		}
+		dir := a.wt.path
+		if strings.TrimSpace(hook.Cwd) != "" {
+			dir = filepath.Join(a.wt.path, hook.Cwd)
+		}
+		if !isDir(dir) {
+			results = append(results, checkResult{name: name, skipped: hook.SkipIfMissing})
+			continue
+		}
+
+		fmt.Printf("Check %s on %s...\n", name, a.wt.branch)
+		fmt.Fprintf(log, "$ %s\n", strings.Join(hook.Command, " "))
+		cmd := exec.Command(hook.Command[0], hook.Command[1:]...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), hookEnv(a.wt.path, a.wt.branch)...)
+		cmd.Stdout = log
+		cmd.Stderr = log
+		err := cmd.Run()
+		fmt.Fprintf(log, "[%s: %s]\n\n", name, checkOutcome(err))
+		results = append(results, checkResult{name: name, passed: err == nil})
+	}
+	return results
+}
+
+func checkOutcome(err error) string {
+	if err != nil {
+		return "failed: " + err.Error()
+	}
+	return "passed"
+}
+
+func printRaceResults(cfg config, attempts []*raceAttempt) {
+	fmt.Println("\nResults:")
+	for i, a := range attempts {
+		fmt.Printf("[%d] %s (%s)\n", i+1, a.wt.branch, agentName(cfg, a.agent))
+		fmt.Printf("    agent:  %s\n", describeAgentStatus(a.status))
+		fmt.Printf("    diff:   %s\n", a.diffstat)
+		if len(a.checks) > 0 {
+			var checks []string
+			for _, c := range a.checks {
+				switch {
+				case c.skipped:
+					checks = append(checks, c.name+" skipped")
+				case c.passed:
+					checks = append(checks, c.name+" passed")
+				default:
+					checks = append(checks, c.name+" FAILED")
+				}
+			}
+			fmt.Printf("    checks: %s\n", strings.Join(checks, ", "))
+		}
+		if metaDir, err := worktreeMetaDir(a.wt.path, a.wt.branch); err == nil {
+			fmt.Printf("    logs:   %s\n", metaDir)
+		}
+		fmt.Printf("    path:   %s\n", a.wt.path)
+	}
+}
+
+// keepRaceAttempt asks which attempt to keep and removes the other worktrees
+// and branches. Without a terminal, or with --yes, all attempts are kept.
+func keepRaceAttempt(cfg config, attempts []*raceAttempt, yes bool) error {
+	if len(attempts) < 2 {
+		return nil
+	}
+	answer := ""
+	if !yes && isInteractive() {
+		answer = promptOptional(fmt.Sprintf("\nKeep which attempt? [1-%d, Enter keeps all]: ", len(attempts)))
+	}
+	if answer == "" {
+		fmt.Println("Kept all attempts; remove the ones you do not want with wtx rm <branch>.")
+		return nil
+	}
+	keep, err := strconv.Atoi(answer)
+	if err != nil || keep < 1 || keep > len(attempts) {
+		return fmt.Errorf("invalid attempt: %s", answer)
+	}
+	for i, a := range attempts {
+		if i+1 == keep {
+			continue
+		}
+		// Only worktrees this race created are thrown away.
+		if !a.created {
+			fmt.Printf("Keeping %s; it existed before the race.\n", a.wt.path)
+			continue
+		}
+		if err := runRemove(cfg, []string{a.wt.path, "--force", "--yes"}); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Kept %s at %s\n", attempts[keep-1].wt.branch, attempts[keep-1].wt.path)
+	return nil
+}
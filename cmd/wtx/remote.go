@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+)
+
+// remoteFlags are the value flags that override baseRemote and pushRemote.
+// Every command that talks to a remote accepts them.
+var remoteFlags = []string{"base-remote", "push-remote"}
+
+// applyRemoteFlags overrides the config with --base-remote and --push-remote.
+func applyRemoteFlags(cfg *config, flags flagValues) {
+	if v := strings.TrimSpace(flags.value("base-remote")); v != "" {
+		cfg.BaseRemote = v
+	}
+	if v := strings.TrimSpace(flags.value("push-remote")); v != "" {
+		cfg.PushRemote = v
+	}
+}
+
+// configuredRemotes returns the distinct remotes wtx works with.
+func configuredRemotes(cfg config) []string {
+	if cfg.BaseRemote == cfg.PushRemote {
+		return []string{cfg.BaseRemote}
+	}
+	return []string{cfg.BaseRemote, cfg.PushRemote}
+}
+
+func isForkWorkflow(cfg config) bool {
+	return cfg.BaseRemote != cfg.PushRemote
+}
+
+// remoteRepoSlug returns "owner/repo" for a remote's URL, or "" when the URL
+// cannot be parsed. Both scp-like (git@host:owner/repo.git) and URL forms
+// (https://host/owner/repo, ssh://git@host/owner/repo.git) are understood.
+func remoteRepoSlug(remote string) string {
+	out, err := runCmdCapture("", "git", "remote", "get-url", remote)
+	if err != nil {
+		return ""
+	}
+	raw := strings.TrimSuffix(strings.TrimSpace(out), "/")
+	raw = strings.TrimSuffix(raw, ".git")
+
+	p := ""
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ""
+		}
+		p = u.Path
+	} else if _, after, ok := strings.Cut(raw, ":"); ok {
+		p = after
+	} else {
+		return ""
+	}
+
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return ""
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// ghRepoArgs returns the --repo arguments that point gh at the repository of
+// the base remote. They are only needed in fork workflows; otherwise gh's own
+// repository detection already picks the right one.
+func ghRepoArgs(cfg config) []string {
+	if !isForkWorkflow(cfg) {
+		return nil
+	}
+	if slug := remoteRepoSlug(cfg.BaseRemote); slug != "" {
+		return []string{"--repo", slug}
+	}
+	return nil
+}
+
+// prHead returns the head reference gh needs for branch: the plain branch name
+// in a single-remote setup, or "owner:branch" when the branch lives in a fork.
+func prHead(cfg config, branch string) string {
+	if !isForkWorkflow(cfg) {
+		return branch
+	}
+	slug := remoteRepoSlug(cfg.PushRemote)
+	owner, _, ok := strings.Cut(slug, "/")
+	if !ok {
+		return branch
+	}
+	return owner + ":" + branch
+}
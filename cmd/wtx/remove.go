@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+func runRemove(cfg config, args []string) error {
+	positional, flags, err := parseFlags(args, []string{"force", "f", "yes", "y", "keep-branch", "delete-remote", "archive"}, nil)
+	if err != nil {
+		return err
+	}
+	force := flags.has("force") || flags.has("f")
+	yes := flags.has("yes") || flags.has("y")
+
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return errors.New("not inside a git repository")
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return errors.New("no worktrees found")
+	}
+	mainWorktree := entries[0].path
+
+	selected, err := selectWorktree(entries, positional)
+	if err != nil {
+		return err
+	}
+	if selected.path == mainWorktree {
+		return errors.New("refusing to remove the main worktree")
+	}
+	branch := strings.TrimPrefix(selected.branch, "refs/heads/")
+	if branch != "" && isProtectedBranch(cfg, branch) {
+		return fmt.Errorf("branch '%s' is protected; refusing to remove its worktree", branch)
+	}
+	if selected.locked && !force {
+		return fmt.Errorf("worktree is locked%s: %s (run wtx unlock first or pass --force)", lockNote(selected), selected.path)
+	}
+
+	// Anything that exists only in this worktree is lost on removal, so
+	// refuse unless forced.
+	if isDir(selected.path) && isWorktreeDirty(selected.path) && !force {
+		return fmt.Errorf("worktree has uncommitted changes: %s (commit or stash them, or pass --force)", selected.path)
+	}
+	merged := false
+	if branch != "" {
+		merged, _ = isBranchMerged(cfg, mainWorktree, branch)
+		if !merged && hasUnpushedCommits(mainWorktree, branch) && !force {
+			return fmt.Errorf("branch '%s' has commits that are not on any remote (push them or pass --force)", branch)
+		}
+	}
+
+	// The remote copy of an unmerged branch may be the only one left once
+	// the local branch is gone, so it is deleted only on request with --force.
+	meta, _ := loadWorktreeMeta(mainWorktree, branch)
+	remoteExists := branch != "" && meta.Pushed && meta.Remote != "" &&
+		runCmdIn(mainWorktree, "git", "ls-remote", "--exit-code", "--heads", meta.Remote, branch) == nil
+	deleteRemote := false
+	if remoteExists {
+		switch {
+		case flags.has("delete-remote"):
+			if !merged && !force {
+				return fmt.Errorf("branch '%s' is not merged; deleting %s/%s would lose its commits (pass --force to delete it anyway)", branch, meta.Remote, branch)
+			}
+			deleteRemote = true
+		case cfg.DeleteRemoteBranches && merged:
+			deleteRemote = true
+		}
+	}
+
+	if deleteRemote && !merged {
+		fmt.Printf("Warning: '%s' is not merged; deleting %s/%s removes the last copy of its commits.\n", branch, meta.Remote, branch)
+	}
+	if !yes && !promptYesNoDefault(fmt.Sprintf("Remove worktree at '%s'? [y/N]: ", selected.path), false) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+	if remoteExists && !deleteRemote && merged && !yes {
+		deleteRemote = promptYesNoDefault(fmt.Sprintf("Also delete remote branch '%s/%s'? [y/N]: ", meta.Remote, branch), false)
+	}
+
+	if isDir(selected.path) {
+		fmt.Println("Running pre-remove hooks...")
+		if err := runHooks(cfg.PreRemoveHooks, selected.path, hookEnv(selected.path, branch)); err != nil {
+			return err
+		}
+	}
+	if branch != "" && (cfg.Archive.Enabled || flags.has("archive")) {
+		dir, err := archiveWorktree(cfg, mainWorktree, selected.path, branch)
+		if err != nil {
+			return fmt.Errorf("failed to archive '%s': %w", branch, err)
+		}
+		fmt.Printf("Archived to: %s\n", dir)
+	}
+
+	removeArgs := []string{"worktree", "remove", "--force"}
+	if selected.locked {
+		removeArgs = append(removeArgs, "--force")
+	}
+	fmt.Printf("Removing worktree at '%s'...\n", selected.path)
+	if err := runCmdStream(mainWorktree, "git", append(removeArgs, selected.path)...); err != nil {
+		return err
+	}
+
+	if branch != "" && !flags.has("keep-branch") {
+		if err := runCmdIn(mainWorktree, "git", "branch", "-d", branch); err != nil {
+			// The unpushed-commit check above already passed (or was forced),
+			// so a branch that is merely unmerged locally is safe to drop.
+			if err2 := runCmdStream(mainWorktree, "git", "branch", "-D", branch); err2 != nil {
+				return err2
+			}
+		}
+		fmt.Printf("Deleted branch: %s\n", branch)
+	}
+	if deleteRemote {
+		fmt.Printf("Deleting remote branch: %s/%s\n", meta.Remote, branch)
+		if err := runCmdStream(mainWorktree, "git", "push", meta.Remote, "--delete", branch); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Running post-remove hooks...")
+	if err := runHooks(cfg.PostRemoveHooks, mainWorktree, hookEnv(selected.path, branch)); err != nil {
+		return err
+	}
+	if branch != "" {
+		_ = removeWorktreeMeta(mainWorktree, branch)
+	}
+	fmt.Printf("Removed worktree: %s\n", selected.path)
+	return nil
+}
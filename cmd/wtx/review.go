@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultReviewPromptTemplate = `Review pull request #{number}: {title}
+{url}
+
+{body}
+
+The PR head is checked out in the current directory. Changes against {base}:
+
+{diff}
+
+Point out bugs, risky changes and missing tests. Do not modify any files.`
+
+type pullRequestInfo struct {
+	Number            int    `json:"number"`
+	Title             string `json:"title"`
+	Body              string `json:"body"`
+	URL               string `json:"url"`
+	HeadRefName       string `json:"headRefName"`
+	BaseRefName       string `json:"baseRefName"`
+	IsCrossRepository bool   `json:"isCrossRepository"`
+	HeadRepository    struct {
+		Name string `json:"name"`
+	} `json:"headRepository"`
+	HeadRepositoryOwner struct {
+		Login string `json:"login"`
+	} `json:"headRepositoryOwner"`
+}
+
+func runReview(cfg config, args []string) error {
+	args, flags, err := parseFlags(args, []string{"no-ai"}, remoteFlags)
+	if err != nil {
+		return err
+	}
+	applyRemoteFlags(&cfg, flags)
+	if err := requireCmd("git"); err != nil {
+		return err
+	}
+	if err := requireCmd("gh"); err != nil {
+		return err
+	}
+	if _, err := runCmdCapture("", "git", "rev-parse", "--is-inside-work-tree"); err != nil {
+		return errors.New("not inside a git repository")
+	}
+	if len(args) == 0 || strings.TrimSpace(args[0]) == "" {
+		return errors.New("pull request number or URL is required (example: wtx review 123)")
+	}
+
+	pr, err := viewPullRequest(cfg, strings.TrimPrefix(strings.TrimSpace(args[0]), "#"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Reviewing PR #%d: %s\n", pr.Number, pr.Title)
+
+	path, err := checkoutPullRequestWorktree(cfg, pr)
+	if err != nil {
+		return err
+	}
+
+	if flags.has("no-ai") {
+		return nil
+	}
+
+	llm := cfg.LLM.Default
+	if len(args) > 1 {
+		llm = args[1]
+	}
+	llm = normalizeLLM(cfg, llm)
+	if llm == "" {
+		return agentSelectionError(cfg)
+	}
+
+	_ = runCmd("git", "fetch", cfg.BaseRemote, pr.BaseRefName)
+	baseRef := cfg.BaseRemote + "/" + pr.BaseRefName
+	diff, err := runCmdCapture(path, "git", "diff", baseRef+"...HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to diff against %s: %s", baseRef, strings.TrimSpace(diff))
+	}
+
+	tmpl := cfg.LLM.ReviewPromptTemplate
+	if strings.TrimSpace(tmpl) == "" {
+		tmpl = defaultReviewPromptTemplate
+	}
+	vars := map[string]string{
+		"{number}": strconv.Itoa(pr.Number),
+		"{title}":  pr.Title,
+		"{body}":   pr.Body,
+		"{url}":    pr.URL,
+		"{base}":   pr.BaseRefName,
+		"{head}":   pr.HeadRefName,
+		"{diff}":   "",
+	}
+	// The diff gets whatever room the rest of the prompt leaves.
+	note := "\n[diff truncated; run `git diff " + baseRef + "...HEAD` for the rest]\n"
+	room := maxPromptBytes - len(replaceTemplates([]string{tmpl}, vars)[0]) - len(note)
+	if len(diff) > room {
+		diff = truncateUTF8(diff, max(room, 0)) + note
+	}
+	vars["{diff}"] = diff
+	prompt := truncateUTF8(replaceTemplates([]string{tmpl}, vars)[0], maxPromptBytes)
+
+	fmt.Printf("Running %s with review prompt...\n", agentName(cfg, llm))
+	return runLLMTask(cfg, llm, path, prompt)
+}
+
+func viewPullRequest(cfg config, ref string) (pullRequestInfo, error) {
+	var pr pullRequestInfo
+	args := []string{"pr", "view", ref, "--json", "number,title,body,url,headRefName,baseRefName,isCrossRepository,headRepository,headRepositoryOwner"}
+	// A URL already names its repository.
+	if !strings.Contains(ref, "://") {
+		args = append(args, ghRepoArgs(cfg)...)
+	}
+	out, err := runCmdCapture("", "gh", args...)
+	if err != nil {
+		return pr, fmt.Errorf("failed to load pull request %s: %s", ref, strings.TrimSpace(out))
+	}
+	if err := json.Unmarshal([]byte(out), &pr); err != nil {
+		return pr, fmt.Errorf("failed to parse gh output: %w", err)
+	}
+	return pr, nil
+}
+
+// checkoutPullRequestWorktree creates or reuses a worktree for the head of pr.
+// Same-repository PRs track their branch on the base remote so fixes can be
+// pushed back; PRs from forks are fetched via refs/pull/<n>/head into a local
+// pr/<n> branch.
+func checkoutPullRequestWorktree(cfg config, pr pullRequestInfo) (string, error) {
+	if !pr.IsCrossRepository {
+		remote := cfg.BaseRemote
+		refspec := "+refs/heads/" + pr.HeadRefName + ":refs/remotes/" + remote + "/" + pr.HeadRefName
+		if err := runCmdStream("", "git", "fetch", remote, refspec); err != nil {
+			return "", err
+		}
+		return checkoutRemoteBranchWorktree(cfg, remote, pr.HeadRefName)
+	}
+
+	fmt.Printf("PR head is in fork %s/%s. Fetching refs/pull/%d/head...\n", pr.HeadRepositoryOwner.Login, pr.HeadRepository.Name, pr.Number)
+	if err := runCmdStream("", "git", "fetch", cfg.BaseRemote, fmt.Sprintf("refs/pull/%d/head", pr.Number)); err != nil {
+		return "", err
+	}
+	head, err := revParseCommit("FETCH_HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	listRaw, err := runCmdCapture("", "git", "worktree", "list", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+	entries := parseWorktreeList(listRaw)
+	if len(entries) == 0 {
+		return "", errors.New("no worktrees found")
+	}
+	mainWorktree := entries[0].path
+
+	branch := fmt.Sprintf("pr/%d", pr.Number)
+	for _, e := range entries {
+		if strings.TrimPrefix(e.branch, "refs/heads/") != branch {
+			continue
+		}
+		fmt.Printf("Branch '%s' is already checked out at: %s\n", branch, e.path)
+		if err := runCmdStream(e.path, "git", "merge", "--ff-only", head); err != nil {
+			fmt.Printf("Could not fast-forward '%s'; resolve divergence manually.\n", branch)
+		}
+		return e.path, nil
+	}
+
+	worktreesDir := filepath.Join(mainWorktree, cfg.WorktreesDir)
+	targetPath := filepath.Join(worktreesDir, strings.ReplaceAll(branch, "/", "__"))
+	if _, err := os.Stat(targetPath); err == nil {
+		return "", fmt.Errorf("target path already exists: %s", targetPath)
+	}
+	if err := os.MkdirAll(worktreesDir, 0o755); err != nil {
+		return "", err
+	}
+	// A pr/<n> branch left from an earlier review may hold local commits, so
+	// it is reused and fast-forwarded rather than reset.
+	if runCmd("git", "show-ref", "--verify", "--quiet", "refs/heads/"+branch) == nil {
+		if err := runCmdStream("", "git", "worktree", "add", targetPath, branch); err != nil {
+			return "", err
+		}
+		if err := runCmdStream(targetPath, "git", "merge", "--ff-only", head); err != nil {
+			fmt.Printf("Could not fast-forward '%s'; resolve divergence manually.\n", branch)
+		}
+	} else if err := runCmdStream("", "git", "worktree", "add", "-b", branch, targetPath, head); err != nil {
+		return "", err
+	}
+
+	meta := worktreeMeta{
+		Branch:    branch,
+		Path:      targetPath,
+		Base:      pr.BaseRefName,
+		CreatedAt: time.Now(),
+	}
+	if err := saveWorktreeMeta(targetPath, meta); err != nil {
+		fmt.Printf("Warning: failed to save wtx metadata: %v\n", err)
+	}
+
+	if err := bootstrapWorktree(cfg, mainWorktree, targetPath, branch); err != nil {
+		return "", err
+	}
+	fmt.Printf("Worktree ready at: %s\n", targetPath)
+	fmt.Printf("Branch: %s (PR #%d head)\n", branch, pr.Number)
+	return targetPath, nil
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// slugify turns free text into an ASCII kebab-case slug for branch names
+// without any network access. Full-width ASCII is narrowed, accented Latin
+// letters are folded, glossary words (mostly Japanese development terms) are
+// translated, remaining katakana and all-hiragana text are romanized, and
+// stop words are dropped. At most n.MaxWords words are kept.
+//
+// When half or more of the letters are dropped, such as kanji missing from the
+// glossary, the words that are left get a short hash of the text appended
+// (or make up "task-<hash>" alone), so that different tasks still get
+// different names; the confirmation prompt lets the user replace it.
+func slugify(n branchNamingCfg, text string) string {
+	runes := []rune(text)
+	// Hiragana next to kanji or katakana is okurigana or particles, which
+	// only add noise; it is romanized only when the text has nothing else.
+	hiraganaOnly := true
+	for _, r := range runes {
+		if unicode.Is(unicode.Han, r) || isKatakana(r) {
+			hiraganaOnly = false
+			break
+		}
+	}
+
+	var words []string
+	var cur strings.Builder
+	// letters counts the runes worth keeping, dropped those that were lost.
+	letters, dropped := 0, 0
+	flush := func() {
+		if cur.Len() > 0 {
+			words = append(words, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		r := foldWidth(runes[i])
+
+		if r < utf8.RuneSelf {
+			if 'A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' {
+				letters++
+				cur.WriteRune(unicode.ToLower(r))
+			} else {
+				flush()
+			}
+			i++
+			continue
+		}
+		if v, ok := latinFold[unicode.ToLower(r)]; ok {
+			letters++
+			cur.WriteString(v)
+			i++
+			continue
+		}
+
+		if word, size := matchGlossary(n.Glossary, runes[i:]); size > 0 {
+			flush()
+			words = append(words, strings.Fields(word)...)
+			letters += size
+			i += size
+			continue
+		}
+
+		if isKatakana(r) || isHiragana(r) && hiraganaOnly {
+			romaji, size := romanizeKana(runes[i:], isKatakana(r))
+			flush()
+			cur.WriteString(romaji)
+			flush()
+			letters += size
+			i += size
+			continue
+		}
+
+		if (unicode.IsLetter(r) || unicode.IsDigit(r)) && !isHiragana(r) {
+			letters++
+			dropped++
+		}
+		flush()
+		i++
+	}
+	flush()
+	lossy := dropped > 0 && dropped*2 >= letters
+
+	kept := make([]string, 0, len(words))
+	for _, w := range words {
+		if !slugStopWords[w] {
+			kept = append(kept, w)
+		}
+	}
+	// A task made only of stop words still deserves a name.
+	if len(kept) == 0 {
+		kept = words
+	}
+	if n.MaxWords > 0 && len(kept) > n.MaxWords {
+		kept = kept[:n.MaxWords]
+	}
+	if lossy {
+		if len(kept) == 0 {
+			kept = []string{"task"}
+		}
+		sum := sha1.Sum([]byte(strings.TrimSpace(text)))
+		kept = append(kept, hex.EncodeToString(sum[:3]))
+	}
+	return strings.Join(kept, "-")
+}
+
+// foldWidth maps full-width ASCII and the ideographic space to ASCII.
+func foldWidth(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return r - 0xFEE0
+	case r == 0x3000:
+		return ' '
+	}
+	return r
+}
+
+func isHiragana(r rune) bool {
+	return r >= 0x3041 && r <= 0x3096
+}
+
+func isKatakana(r rune) bool {
+	return r >= 0x30A1 && r <= 0x30FA || r == 0x30FC
+}
+
+// matchGlossary returns the translation of the longest glossary entry at the
+// start of runes and its length in runes.
+func matchGlossary(glossary map[string]string, runes []rune) (string, int) {
+	for size := min(len(runes), maxGlossaryKeyLen); size > 0; size-- {
+		if v, ok := glossary[string(runes[:size])]; ok {
+			return v, size
+		}
+	}
+	return "", 0
+}
+
+// maxGlossaryKeyLen bounds glossary lookups; longer keys never match.
+const maxGlossaryKeyLen = 12
+
+// romanizeKana romanizes the run of katakana (or hiragana) at the start of
+// runes using Hepburn spelling and returns it with the number of runes
+// consumed. Katakana is handled through its hiragana equivalent.
+func romanizeKana(runes []rune, katakana bool) (string, int) {
+	inRun := func(r rune) bool {
+		if katakana {
+			return isKatakana(r)
+		}
+		return isHiragana(r)
+	}
+
+	var b strings.Builder
+	geminate := false
+	i := 0
+	for ; i < len(runes) && inRun(runes[i]); i++ {
+		r := toHiragana(runes[i])
+		switch r {
+		case 0x30FC: // long vowel mark: repeat the vowel, カート → kaato
+			if str := b.String(); str != "" && strings.ContainsRune("aiueo", rune(str[len(str)-1])) {
+				b.WriteByte(str[len(str)-1])
+			}
+			continue
+		case 'っ':
+			geminate = true
+			continue
+		}
+
+		syl := kanaRomaji[r]
+		if i+1 < len(runes) && inRun(runes[i+1]) {
+			if v, ok := smallKana[toHiragana(runes[i+1])]; ok && syl != "" {
+				syl = combineKana(syl, v)
+				i++
+			}
+		}
+		if geminate && syl != "" {
+			if strings.HasPrefix(syl, "ch") {
+				b.WriteByte('t')
+			} else if c := syl[0]; !strings.ContainsRune("aiueon", rune(c)) {
+				b.WriteByte(c)
+			}
+			geminate = false
+		}
+		b.WriteString(syl)
+	}
+	return b.String(), i
+}
+
+func toHiragana(r rune) rune {
+	if r >= 0x30A1 && r <= 0x30F6 {
+		return r - 0x60
+	}
+	return r
+}
+
+// combineKana joins a syllable with a following small kana: きゃ → kya,
+// しゃ → sha, ファ → fa, ウィ → wi.
+func combineKana(syl, small string) string {
+	if strings.HasPrefix(small, "y") {
+		switch syl {
+		case "shi", "chi", "ji":
+			return syl[:len(syl)-1] + small[1:]
+		}
+		if strings.HasSuffix(syl, "i") {
+			return syl[:len(syl)-1] + small
+		}
+		return syl + small
+	}
+	if syl == "u" {
+		return "w" + small
+	}
+	return syl[:len(syl)-1] + small
+}
+
+var kanaRomaji = map[rune]string{
+	'あ': "a", 'い': "i", 'う': "u", 'え': "e", 'お': "o",
+	'か': "ka", 'き': "ki", 'く': "ku", 'け': "ke", 'こ': "ko",
+	'さ': "sa", 'し': "shi", 'す': "su", 'せ': "se", 'そ': "so",
+	'た': "ta", 'ち': "chi", 'つ': "tsu", 'て': "te", 'と': "to",
+	'な': "na", 'に': "ni", 'ぬ': "nu", 'ね': "ne", 'の': "no",
+	'は': "ha", 'ひ': "hi", 'ふ': "fu", 'へ': "he", 'ほ': "ho",
+	'ま': "ma", 'み': "mi", 'む': "mu", 'め': "me", 'も': "mo",
+	'や': "ya", 'ゆ': "yu", 'よ': "yo",
+	'ら': "ra", 'り': "ri", 'る': "ru", 'れ': "re", 'ろ': "ro",
+	'わ': "wa", 'ゐ': "i", 'ゑ': "e", 'を': "o", 'ん': "n",
+	'が': "ga", 'ぎ': "gi", 'ぐ': "gu", 'げ': "ge", 'ご': "go",
+	'ざ': "za", 'じ': "ji", 'ず': "zu", 'ぜ': "ze", 'ぞ': "zo",
+	'だ': "da", 'ぢ': "ji", 'づ': "zu", 'で': "de", 'ど': "do",
+	'ば': "ba", 'び': "bi", 'ぶ': "bu", 'べ': "be", 'ぼ': "bo",
+	'ぱ': "pa", 'ぴ': "pi", 'ぷ': "pu", 'ぺ': "pe", 'ぽ': "po",
+	'ゔ': "vu",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo", 'ゎ': "wa",
+}
+
+// smallKana are the small kana that combine with the preceding syllable.
+var smallKana = map[rune]string{
+	'ゃ': "ya", 'ゅ': "yu", 'ょ': "yo",
+	'ぁ': "a", 'ぃ': "i", 'ぅ': "u", 'ぇ': "e", 'ぉ': "o",
+}
+
+var latinFold = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+	'ç': "c", 'ć': "c", 'ĉ': "c", 'ċ': "c", 'č': "c",
+	'ď': "d", 'đ': "d", 'ð': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ĕ': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+	'ĝ': "g", 'ğ': "g", 'ġ': "g", 'ģ': "g",
+	'ĥ': "h", 'ħ': "h",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ĩ': "i", 'ī': "i", 'ĭ': "i", 'į': "i", 'ı': "i",
+	'ĵ': "j", 'ķ': "k",
+	'ĺ': "l", 'ļ': "l", 'ľ': "l", 'ŀ': "l", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ņ': "n", 'ň': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ŏ': "o", 'ő': "o",
+	'ŕ': "r", 'ŗ': "r", 'ř': "r",
+	'ś': "s", 'ŝ': "s", 'ş': "s", 'š': "s", 'ș': "s",
+	'ţ': "t", 'ť': "t", 'ŧ': "t", 'ț': "t",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ũ': "u", 'ū': "u", 'ŭ': "u", 'ů': "u", 'ű': "u", 'ų': "u",
+	'ŵ': "w", 'ý': "y", 'ÿ': "y", 'ŷ': "y",
+	'ź': "z", 'ż': "z", 'ž': "z",
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'þ': "th",
+}
+
+var slugStopWords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "of": true,
+	"to": true, "in": true, "on": true, "for": true, "with": true, "from": true,
+	"by": true, "at": true, "as": true, "into": true, "is": true, "are": true,
+	"be": true, "this": true, "that": true, "it": true, "please": true,
+}
+
+// defaultSlugGlossary translates common Japanese development terms that
+// cannot be romanized meaningfully. branchNaming.glossary adds to it.
+var defaultSlugGlossary = map[string]string{
+	"追加": "add", "削除": "remove", "修正": "fix", "変更": "change", "更新": "update",
+	"作成": "create", "実装": "implement", "改善": "improve", "対応": "support", "整理": "cleanup",
+	"移行": "migrate", "導入": "introduce", "廃止": "deprecate", "統合": "merge", "分割": "split",
+	"共通化": "extract-common", "高速化": "speed-up", "最適化": "optimize", "自動化": "automate",
+	"不具合": "bug", "機能": "feature", "画面": "screen", "一覧": "list", "詳細": "detail",
+	"検索": "search", "登録": "register", "編集": "edit", "保存": "save", "表示": "display",
+	"設定": "settings", "認証": "auth", "権限": "permission", "通知": "notification",
+	"管理": "admin", "管理画面": "admin", "決済": "payment", "予約": "reservation",
+	"注文": "order", "商品": "product", "在庫": "stock", "会員": "member", "顧客": "customer",
+	"請求": "billing", "集計": "aggregate", "入力": "input", "出力": "output",
+	"読み込み": "load", "書き込み": "write", "取得": "fetch", "送信": "send", "受信": "receive",
+	"日付": "date", "時間": "time", "言語": "language", "翻訳": "translation", "多言語": "i18n",
+	"文言": "copy", "型": "type", "依存": "dependency", "環境": "env", "開発": "dev",
+	"本番": "production", "性能": "performance", "速度": "speed", "警告": "warning",
+	"エラー": "error", "バグ": "bug", "ログイン": "login", "ログアウト": "logout", "ログ": "log",
+	"ユーザー": "user", "ユーザ": "user", "パスワード": "password", "アカウント": "account",
+	"プロフィール": "profile", "メール": "email", "ボタン": "button", "フォーム": "form",
+	"ページ": "page", "メニュー": "menu", "ヘッダー": "header", "フッター": "footer",
+	"モーダル": "modal", "ダイアログ": "dialog", "レイアウト": "layout", "デザイン": "design",
+	"コンポーネント": "component", "サーバー": "server", "サーバ": "server",
+	"クライアント": "client", "キャッシュ": "cache", "データ": "data", "ファイル": "file",
+	"アップロード": "upload", "ダウンロード": "download", "リンク": "link", "テスト": "test",
+	"ドキュメント": "docs", "リファクタリング": "refactor", "リファクタ": "refactor",
+	"セッション": "session", "トークン": "token", "バリデーション": "validation",
+	"リクエスト": "request", "レスポンス": "response", "パフォーマンス": "performance",
+	"ビルド": "build", "デプロイ": "deploy", "インポート": "import", "エクスポート": "export",
+	"フィルター": "filter", "ソート": "sort", "カレンダー": "calendar",
+	"ダッシュボード": "dashboard", "スタイル": "style", "アイコン": "icon", "画像": "image",
+	"検索機能": "search", "ライブラリ": "library", "バージョン": "version",
+	"連携": "integration", "接続": "connection", "通信": "network", "処理": "processing",
+	"計算": "calculate", "初期化": "init", "非同期": "async", "例外": "exception",
+	"並び替え": "sort", "絞り込み": "filter", "共通": "common", "外部": "external", "内部": "internal",
+	"クリア": "clear", "リスト": "list", "テーブル": "table", "タブ": "tab", "グラフ": "chart",
+	"チェックボックス": "checkbox", "ウィジェット": "widget", "サイドバー": "sidebar",
+	"ナビゲーション": "navigation", "ページネーション": "pagination",
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	n := branchNamingCfg{}
+	if err := normalizeBranchNaming(&n); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Add login form", "add-login-form"},
+		{"Fix the crash on start", "fix-crash-start"},
+		{"ＡＰＩ　ｆｉｘ", "api-fix"},
+		{"Café menü", "cafe-menu"},
+		{"ログイン画面を修正", "login-screen-fix"},
+		{"ユーザー一覧にCSV出力を追加", "user-list-csv-output-add"},
+		{"OAuth2のトークン更新", "oauth2-token-update"},
+		{"カートを追加", "kaato-add"},
+		{"コーヒー", "koohii"},
+		{"ひらがなのみ", "hiragananomi"},
+		{"修正 bug in 画面 v2", "fix-bug-screen-v2"},
+		{"the", "the"},
+		{"", ""},
+		{"one two three four five six seven", "one-two-three-four-five-six"},
+	}
+	for _, tt := range tests {
+		if got := slugify(n, tt.text); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSlugifyLossy(t *testing.T) {
+	n := branchNamingCfg{}
+	if err := normalizeBranchNaming(&n); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"請求書の宛名欄を拡張", `^billing-[0-9a-f]{6}$`},
+		{"宛名を拡張", `^task-[0-9a-f]{6}$`},
+		{"帳票出力", `^output-[0-9a-f]{6}$`},
+	}
+	seen := map[string]bool{}
+	for _, tt := range tests {
+		got := slugify(n, tt.text)
+		if !regexp.MustCompile(tt.want).MatchString(got) {
+			t.Errorf("slugify(%q) = %q, want a match for %s", tt.text, got, tt.want)
+		}
+		if seen[got] {
+			t.Errorf("slugify(%q) = %q, already produced for another task", tt.text, got)
+		}
+		seen[got] = true
+	}
+
+	n.Glossary["帳票"] = "report"
+	if got := slugify(n, "帳票出力"); got != "report-output" {
+		t.Errorf("slugify with glossary = %q, want report-output", got)
+	}
+}
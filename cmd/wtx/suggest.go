@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Values of llm.branchNameFormat.
+const (
+	branchNameFormatJSON = "json"
+	branchNameFormatText = "text"
+)
+
+// Where a branch name came from; recorded in the worktree metadata.
+const (
+	branchSourceJSON     = "json"
+	branchSourceRegex    = "regex"
+	branchSourceSlug     = "slug"
+	branchSourceEdited   = "edited"
+	branchSourceExisting = "existing"
+)
+
+const defaultBranchNameJSONPromptTemplate = `Suggest a git branch name for the task below.
+Reply with only a JSON object with these string fields and nothing else:
+- "branch": short English kebab-case name without a type prefix
+- "type": one of {types}
+- "summary": one sentence describing the change
+- "commitTitle": an imperative commit title for the change
+
+Task: {task}`
+
+// branchSuggestion is a branch name proposed for a task. With the JSON format
+// the AI fills in all fields; otherwise only Branch is set.
+type branchSuggestion struct {
+	Branch      string `json:"branch"`
+	Type        string `json:"type,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	CommitTitle string `json:"commitTitle,omitempty"`
+	Source      string `json:"source"`
+}
+
+// branchNameQuery is one way of asking an AI for a branch name: an agent CLI
+// or an HTTP provider.
+type branchNameQuery struct {
+	label    string
+	cacheKey string
+	run      func(ctx context.Context) (string, error)
+}
+
+// suggestBranchNames asks the AI for branch names. JSON replies are parsed
+// first; free-form output falls back to the regex scraper, and a failed, slow
+// or missing AI to a local slug of the task's first line. Answers are cached
+// per prompt so a retried command does not query the AI again; useCache false
+// forces a fresh answer.
+func suggestBranchNames(cfg config, task, llm string, useCache bool) []branchSuggestion {
+	if q, ok := newBranchNameQuery(cfg, task, llm); ok {
+		if useCache {
+			if v, ok := loadCachedBranchNames(q.cacheKey); ok {
+				fmt.Println("Using cached branch name suggestion for this task.")
+				return v
+			}
+		}
+		if v := askBranchNames(cfg, q); len(v) > 0 {
+			if err := saveCachedBranchNames(q.cacheKey, v); err != nil {
+				fmt.Printf("Warning: failed to cache branch names: %v\n", err)
+			}
+			return v
+		}
+	}
+
+	firstLine, _, _ := strings.Cut(strings.TrimSpace(task), "\n")
+	fallback := slugify(cfg.BranchNaming, firstLine)
+	if fallback == "" {
+		fallback = "task"
+	}
+	return []branchSuggestion{{Branch: fallback, Source: branchSourceSlug}}
+}
+
+// newBranchNameQuery picks llm.branchNameProvider when set, otherwise the
+// selected agent CLI. It reports false when neither is usable.
+func newBranchNameQuery(cfg config, task, llm string) (branchNameQuery, bool) {
+	aiCfg, ok := cfg.LLM.Commands[llm]
+	argsTemplate := aiCfg.BranchNameArgsTemplate
+	promptTemplate := cfg.LLM.BranchNamePromptTemplate
+	if cfg.LLM.BranchNameFormat == branchNameFormatJSON {
+		if len(aiCfg.BranchNameJSONArgsTemplate) > 0 {
+			argsTemplate = aiCfg.BranchNameJSONArgsTemplate
+		}
+		promptTemplate = cfg.LLM.BranchNameJSONPromptTemplate
+	}
+	prompt := replaceTemplates([]string{promptTemplate}, map[string]string{
+		"{task}":  task,
+		"{types}": strings.Join(branchTypes(cfg.BranchNaming), ", "),
+	})[0]
+
+	if name := cfg.LLM.BranchNameProvider; name != "" {
+		p := cfg.LLM.Providers[name]
+		return branchNameQuery{
+			label:    name + " (" + p.Model + ")",
+			cacheKey: branchNameCacheKey(name, []string{p.BaseURL, p.Model, prompt}),
+			run: func(ctx context.Context) (string, error) {
+				return chatCompletion(ctx, p, prompt, cfg.LLM.BranchNameFormat == branchNameFormatJSON)
+			},
+		}, true
+	}
+
+	if !ok || !commandExists(agentBinary(cfg, llm)) || len(argsTemplate) == 0 {
+		return branchNameQuery{}, false
+	}
+	args := replaceTemplates(argsTemplate, map[string]string{
+		"{prompt}": prompt,
+		"{task}":   task,
+	})
+	return branchNameQuery{
+		label:    agentName(cfg, llm),
+		cacheKey: branchNameCacheKey(agentBinary(cfg, llm), args),
+		run: func(ctx context.Context) (string, error) {
+			return runCmdCaptureContext(ctx, agentDir(cfg, llm, ""), agentEnv(cfg, llm), agentBinary(cfg, llm), args...)
+		},
+	}, true
+}
+
+// askBranchNames runs q within llm.branchNameTimeout and extracts branch
+// names from the answer. It returns nil when the AI fails, times out or gives
+// nothing usable.
+func askBranchNames(cfg config, q branchNameQuery) []branchSuggestion {
+	ctx := context.Background()
+	timeout, _ := time.ParseDuration(cfg.LLM.BranchNameTimeout)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	stop := startSpinner("Generating branch name with " + q.label)
+	out, err := q.run(ctx)
+	stop()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		fmt.Printf("%s did not answer within %s; using a local branch name.\n", q.label, timeout)
+		return nil
+	}
+	if err != nil {
+		fmt.Printf("%s failed to suggest a branch name (%v); using a local branch name.\n", q.label, err)
+		return nil
+	}
+
+	if cfg.LLM.BranchNameFormat == branchNameFormatJSON {
+		if v := parseBranchSuggestions(out); len(v) > 0 {
+			return v
+		}
+	}
+	var found []branchSuggestion
+	for _, name := range extractBranchCandidates(cfg.BranchNaming, out) {
+		found = append(found, branchSuggestion{Branch: name, Source: branchSourceRegex})
+	}
+	return found
+}
+
+func branchTypes(n branchNamingCfg) []string {
+	types := make([]string, 0, len(n.Prefixes))
+	for _, p := range n.Prefixes {
+		types = append(types, strings.TrimSuffix(p, "/"))
+	}
+	return types
+}
+
+// parseBranchSuggestions finds JSON objects with a "branch" field anywhere in
+// out, the last one first. Objects may be wrapped in prose, code fences, JSON
+// Lines event streams or result envelopes whose string fields hold the reply
+// (as printed by claude --output-format json or codex exec --json).
+func parseBranchSuggestions(out string) []branchSuggestion {
+	var found []branchSuggestion
+	collectBranchSuggestions(out, &found, 0)
+
+	var result []branchSuggestion
+	for i := len(found) - 1; i >= 0; i-- {
+		dup := false
+		for _, s := range result {
+			if s.Branch == found[i].Branch && s.Type == found[i].Type {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			result = append(result, found[i])
+		}
+	}
+	return result
+}
+
+// maxEnvelopeDepth bounds how deeply JSON encoded inside JSON strings is
+// unwrapped.
+const maxEnvelopeDepth = 4
+
+func collectBranchSuggestions(text string, found *[]branchSuggestion, depth int) {
+	if depth > maxEnvelopeDepth {
+		return
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' {
+			continue
+		}
+		dec := json.NewDecoder(strings.NewReader(text[i:]))
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			continue
+		}
+		walkBranchSuggestions(v, found, depth)
+		i += int(dec.InputOffset()) - 1
+	}
+}
+
+func walkBranchSuggestions(v any, found *[]branchSuggestion, depth int) {
+	switch x := v.(type) {
+	case map[string]any:
+		if b, ok := x["branch"].(string); ok {
+			s := branchSuggestion{Branch: strings.TrimSpace(b), Source: branchSourceJSON}
+			s.Type, _ = x["type"].(string)
+			s.Summary, _ = x["summary"].(string)
+			s.CommitTitle, _ = x["commitTitle"].(string)
+			// Skip the schema when the CLI echoes the prompt.
+			if s.Branch != "" && !strings.ContainsAny(s.Branch, "<>") {
+				*found = append(*found, s)
+			}
+			return
+		}
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			walkBranchSuggestions(x[k], found, depth)
+		}
+	case []any:
+		for _, e := range x {
+			walkBranchSuggestions(e, found, depth)
+		}
+	case string:
+		if strings.Contains(x, "{") {
+			collectBranchSuggestions(x, found, depth+1)
+		}
+	}
+}
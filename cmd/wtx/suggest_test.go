@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBranchSuggestions(t *testing.T) {
+	addLogin := branchSuggestion{Branch: "add-login", Type: "feature", Summary: "Adds a login form.", CommitTitle: "Add login form", Source: branchSourceJSON}
+	tests := []struct {
+		name string
+		out  string
+		want []branchSuggestion
+	}{
+		{
+			"plain object",
+			`{"branch":"add-login","type":"feature","summary":"Adds a login form.","commitTitle":"Add login form"}`,
+			[]branchSuggestion{addLogin},
+		},
+		{
+			"prose and code fence",
+			"Sure, here you go:\n```json\n{\"branch\": \" add-login \", \"type\": \"feature\", \"summary\": \"Adds a login form.\", \"commitTitle\": \"Add login form\"}\n```\n",
+			[]branchSuggestion{addLogin},
+		},
+		{
+			"result envelope",
+			`{"type":"result","result":"{\"branch\":\"fix-crash\",\"type\":\"fix\"}"}`,
+			[]branchSuggestion{{Branch: "fix-crash", Type: "fix", Source: branchSourceJSON}},
+		},
+		{
+			"json lines, last first",
+			"{\"msg\":{\"branch\":\"first\"}}\n{\"msg\":{\"branch\":\"second\"}}\n",
+			[]branchSuggestion{{Branch: "second", Source: branchSourceJSON}, {Branch: "first", Source: branchSourceJSON}},
+		},
+		{
+			"duplicates dropped",
+			`{"branch":"a","type":"fix"} {"branch":"a","type":"fix"}`,
+			[]branchSuggestion{{Branch: "a", Type: "fix", Source: branchSourceJSON}},
+		},
+		{
+			"echoed schema skipped",
+			`{"branch":"<kebab-case name>"} {"branch":"real-name"}`,
+			[]branchSuggestion{{Branch: "real-name", Source: branchSourceJSON}},
+		},
+		{"no json", "feature/add-login", nil},
+		{"broken json", `{"branch": "add-login"`, nil},
+		{"empty branch", `{"branch": ""}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseBranchSuggestions(tt.out)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBranchSuggestions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
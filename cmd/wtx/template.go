@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxPromptFileBytes caps each embedded file and command output so that one
+// of them cannot crowd out the rest. The rendered prompt as a whole is capped
+// at maxPromptBytes.
+const maxPromptFileBytes = 50_000
+
+const defaultPromptTemplateLayout = "{preamble}\n\n{task}\n\n{files}\n\n{commands}"
+
+// promptTemplateCfg is a named prompt for the first agent run. Template may
+// use {task}, {branch}, {base}, {issue}, {issueBody}, {preamble}, {files} and
+// {commands}; without one the preamble, task, files and command output are
+// joined in that order.
+type promptTemplateCfg struct {
+	Preamble string     `json:"preamble"`
+	Template string     `json:"template"`
+	Files    []string   `json:"files"`
+	Commands [][]string `json:"commands"`
+}
+
+// promptContext is what a prompt template is rendered with.
+type promptContext struct {
+	task      string
+	branch    string
+	base      string
+	issue     string
+	issueBody string
+	worktree  string
+}
+
+func validatePromptTemplates(l llmCfg) error {
+	for name, t := range l.PromptTemplates {
+		for _, c := range t.Commands {
+			if len(c) == 0 {
+				return fmt.Errorf("llm.promptTemplates.%s has an empty command", name)
+			}
+		}
+	}
+	if l.DefaultPromptTemplate != "" {
+		if _, ok := l.PromptTemplates[l.DefaultPromptTemplate]; !ok {
+			return fmt.Errorf("llm.defaultPromptTemplate %q is not defined in llm.promptTemplates", l.DefaultPromptTemplate)
+		}
+	}
+	return nil
+}
+
+// promptTemplateName returns the --template flag value, falling back to
+// llm.defaultPromptTemplate. "none" disables templating.
+func promptTemplateName(cfg config, flags flagValues) (string, error) {
+	name := cfg.LLM.DefaultPromptTemplate
+	if flags.has("template") {
+		name = strings.TrimSpace(flags.value("template"))
+	}
+	if name == "" || name == "none" {
+		return "", nil
+	}
+	if _, ok := cfg.LLM.PromptTemplates[name]; !ok {
+		return "", fmt.Errorf("unknown prompt template: %s", name)
+	}
+	return name, nil
+}
+
+// renderPromptTemplate builds the agent's first prompt from the named
+// template. Files are read from and commands run in the worktree.
+func renderPromptTemplate(cfg config, name string, pc promptContext) string {
+	t := cfg.LLM.PromptTemplates[name]
+	layout := t.Template
+	if strings.TrimSpace(layout) == "" {
+		layout = defaultPromptTemplateLayout
+	}
+
+	if strings.Contains(layout+t.Preamble, "{issueBody}") && pc.issueBody == "" {
+		pc.issueBody = fetchIssueBody(cfg, pc.issue)
+	}
+	vars := map[string]string{
+		"{task}":      pc.task,
+		"{branch}":    pc.branch,
+		"{base}":      pc.base,
+		"{issue}":     pc.issue,
+		"{issueBody}": pc.issueBody,
+	}
+	// The preamble comes from the config and may use the same placeholders;
+	// text from the task, issue, files and commands is never expanded.
+	vars["{preamble}"] = replaceTemplates([]string{strings.TrimSpace(t.Preamble)}, vars)[0]
+	if strings.Contains(layout, "{files}") {
+		vars["{files}"] = renderPromptFiles(pc.worktree, t.Files)
+	}
+	if strings.Contains(layout, "{commands}") {
+		vars["{commands}"] = renderPromptCommands(pc.worktree, t.Commands)
+	}
+	out := replaceTemplates([]string{layout}, vars)[0]
+	// Empty sections leave runs of blank lines behind.
+	out = strings.TrimSpace(regexpReplace(out, `\n{3,}`, "\n\n"))
+	if len(out) > maxPromptBytes {
+		fmt.Printf("Warning: prompt template %s renders %d bytes; truncated to %d.\n", name, len(out), maxPromptBytes)
+		out = truncateUTF8(out, maxPromptBytes)
+	}
+	return out
+}
+
+func renderPromptFiles(worktree string, files []string) string {
+	var parts []string
+	for _, f := range files {
+		raw, err := os.ReadFile(filepath.Join(worktree, f))
+		if err != nil {
+			continue
+		}
+		content := string(raw)
+		if len(content) > maxPromptFileBytes {
+			content = truncateUTF8(content, maxPromptFileBytes) + "\n[truncated]"
+		}
+		parts = append(parts, "--- "+f+" ---\n"+strings.TrimSpace(content))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func renderPromptCommands(worktree string, commands [][]string) string {
+	var parts []string
+	for _, c := range commands {
+		if !commandExists(c[0]) {
+			continue
+		}
+		out, err := runCmdCapture(worktree, c[0], c[1:]...)
+		if err != nil {
+			fmt.Printf("Warning: prompt command %q failed: %v\n", strings.Join(c, " "), err)
+		}
+		if len(out) > maxPromptFileBytes {
+			out = truncateUTF8(out, maxPromptFileBytes) + "\n[truncated]"
+		}
+		parts = append(parts, "$ "+strings.Join(c, " ")+"\n"+strings.TrimSpace(out))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// fetchIssueBody loads the body of a GitHub issue number with gh. Issue-tracker
+// keys and failures give "".
+func fetchIssueBody(cfg config, issue string) string {
+	if _, err := strconv.Atoi(issue); err != nil || !commandExists("gh") {
+		return ""
+	}
+	out, err := runCmdCapture("", "gh", append([]string{"issue", "view", issue, "--json", "body"}, ghRepoArgs(cfg)...)...)
+	if err != nil {
+		return ""
+	}
+	var v struct {
+		Body string `json:"body"`
+	}
+	if json.Unmarshal([]byte(out), &v) != nil {
+		return ""
+	}
+	return strings.TrimSpace(v.Body)
+}
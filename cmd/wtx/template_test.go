@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderPromptTemplate(t *testing.T) {
+	cfg := config{LLM: llmCfg{PromptTemplates: map[string]promptTemplateCfg{
+		"plain": {Preamble: "Work on {branch}.", Template: "{preamble}\n\n{task}\n\n{files}\n\nIssue: {issue}"},
+	}}}
+	got := renderPromptTemplate(cfg, "plain", promptContext{
+		task:   "Document the {issue} and {branch} placeholders",
+		branch: "feature/docs",
+		issue:  "PROJ-1",
+	})
+	want := "Work on feature/docs.\n\nDocument the {issue} and {branch} placeholders\n\nIssue: PROJ-1"
+	if got != want {
+		t.Errorf("renderPromptTemplate() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderPromptTemplateTruncates(t *testing.T) {
+	cfg := config{LLM: llmCfg{PromptTemplates: map[string]promptTemplateCfg{
+		"big": {Template: "x{task}"},
+	}}}
+	got := renderPromptTemplate(cfg, "big", promptContext{task: strings.Repeat("日本", maxPromptBytes)})
+	if len(got) > maxPromptBytes {
+		t.Errorf("rendered %d bytes, want at most %d", len(got), maxPromptBytes)
+	}
+	if !utf8.ValidString(got) {
+		t.Error("rendered prompt was cut inside a character")
+	}
+}